@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ftl/si5351/pkg/si5351"
+)
+
+var enableCmd = &cobra.Command{
+	Use:   "enable [output]...",
+	Short: "Enable the given outputs, all other outputs keep their state",
+	Run:   runSi5351(runEnable(true)),
+}
+
+var disableCmd = &cobra.Command{
+	Use:   "disable [output]...",
+	Short: "Disable the given outputs, all other outputs keep their state",
+	Run:   runSi5351(runEnable(false)),
+}
+
+func init() {
+	rootCmd.AddCommand(enableCmd)
+	rootCmd.AddCommand(disableCmd)
+}
+
+func runEnable(enabled bool) func(cmd *cobra.Command, args []string, device *si5351.Si5351) {
+	return func(cmd *cobra.Command, args []string, device *si5351.Si5351) {
+		outputs := make([]si5351.OutputIndex, len(args))
+		for i, arg := range args {
+			output, err := parseOutput(arg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			outputs[i] = output
+		}
+
+		if err := device.Load(); err != nil {
+			log.Fatal(err)
+		}
+		if err := device.EnableOutputs(enabled, outputs...); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ftl/si5351/pkg/si5351"
+)
+
+var fanoutFlags = struct {
+	drive  int
+	invert bool
+}{}
+
+var fanoutCmd = &cobra.Command{
+	Use:   "fanout [output] [source]",
+	Short: "Output a buffered copy of the crystal (xtal), CLKIN (clkin), or the shared Multisynth (ms) on the given output",
+	Long: `Output a buffered copy of the crystal (xtal), CLKIN (clkin), or the shared Multisynth (ms) on the given output.
+The shared Multisynth is the Multisynth of CLK0 for the outputs CLK1-CLK3 and the Multisynth of CLK4 for the outputs CLK5-CLK7.
+
+Example: fanout --invert 1 ms # output the inverted signal of CLK0 on CLK1 to form a differential pair
+`,
+	Run: runSi5351(runFanout),
+}
+
+func init() {
+	rootCmd.AddCommand(fanoutCmd)
+
+	fanoutCmd.Flags().IntVar(&fanoutFlags.drive, "drive", 2, "the output drive strength in mA (2, 4, 6, 8)")
+	fanoutCmd.Flags().BoolVar(&fanoutFlags.invert, "invert", false, "invert the output")
+}
+
+func runFanout(cmd *cobra.Command, args []string, device *si5351.Si5351) {
+	if len(args) != 2 {
+		log.Fatal("wrong number of arguments, try fanout --help")
+	}
+
+	output, err := parseOutput(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	inputSource, err := parseFanoutSource(args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	drive := toOutputDrive(fanoutFlags.drive)
+
+	if err := device.Load(); err != nil {
+		log.Fatal(err)
+	}
+	if err := device.SetupFanoutOutput(output, inputSource, fanoutFlags.invert, drive); err != nil {
+		log.Fatal(err)
+	}
+	if err := device.Output(output).Enable(true); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"log"
+
 	"github.com/spf13/cobra"
 
 	"github.com/ftl/si5351/pkg/si5351"
@@ -20,6 +22,10 @@ func init() {
 }
 
 func runInit(cmd *cobra.Command, args []string, device *si5351.Si5351) {
-	device.StartSetup()
-	device.FinishSetup()
+	if err := device.StartSetup(); err != nil {
+		log.Fatal(err)
+	}
+	if err := device.FinishSetup(); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ftl/si5351/pkg/si5351"
+)
+
+var oebCmd = &cobra.Command{
+	Use:   "oeb [output]...",
+	Short: "Let the given outputs follow the OEB pin, all other outputs ignore the OEB pin",
+	Long: `Let the given outputs follow the OEB pin, all other outputs ignore the OEB pin.
+Without any given output, none of the outputs follows the OEB pin.
+
+Example: oeb 0 1 # CLK0 and CLK1 are enabled and disabled by the OEB pin
+`,
+	Run: runSi5351(runOEB),
+}
+
+func init() {
+	rootCmd.AddCommand(oebCmd)
+}
+
+func runOEB(cmd *cobra.Command, args []string, device *si5351.Si5351) {
+	controlled := make([]si5351.OutputIndex, 0, len(args))
+	for _, arg := range args {
+		output, err := parseOutput(arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		controlled = append(controlled, output)
+	}
+	uncontrolled := make([]si5351.OutputIndex, 0, 8)
+	for output := si5351.Clk0; output <= si5351.Clk7; output++ {
+		if device.Variant.HasOutput(output) && !containsOutput(controlled, output) {
+			uncontrolled = append(uncontrolled, output)
+		}
+	}
+
+	if err := device.Load(); err != nil {
+		log.Fatal(err)
+	}
+	if err := device.SetOEBControl(false, uncontrolled...); err != nil {
+		log.Fatal(err)
+	}
+	if err := device.SetOEBControl(true, controlled...); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func containsOutput(outputs []si5351.OutputIndex, output si5351.OutputIndex) bool {
+	for _, o := range outputs {
+		if o == output {
+			return true
+		}
+	}
+	return false
+}
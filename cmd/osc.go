@@ -9,16 +9,22 @@ import (
 )
 
 var oscFlags = struct {
-	drive  int
-	intDiv bool
-	noInit bool
+	drive        int
+	disableState string
+	intDiv       bool
+	integerOnly  bool
+	tolerance    string
+	noInit       bool
+	spread       string
 }{}
 
 var oscCmd = &cobra.Command{
-	Use:   "osc [freq0] [freq1] [freq2] [freq3] [freq4] [freq5]",
-	Short: "Output the given frequencies on the outputs CLK0-CLK5 using PLL A",
-	Long: `Output the given frequencies on the outputs CLK0-CLK5 using PLL A.
-If the list of given frequencies is shorter than six entries, only the outputs with given frequencies are setup.
+	Use:   "osc [freq0] [freq1] [freq2] [freq3] [freq4] [freq5] [freq6] [freq7]",
+	Short: "Output the given frequencies on the outputs CLK0-CLK7",
+	Long: `Output the given frequencies on the outputs CLK0-CLK7.
+If the list of given frequencies is shorter than eight entries, only the outputs with given frequencies are setup.
+The outputs are distributed on PLL A and PLL B by the frequency planner. CLK6 and CLK7 only support even integer
+dividers, like all outputs with --integer. Outputs with integer dividers need to share the frequency of their PLL.
 
 Example: osc 10M 5M 3500k 3400k # output 10MHz, 5MHz, 3500kHz, and 3400kHz on the outputs CLK0-CLK4
 `,
@@ -29,20 +35,42 @@ func init() {
 	rootCmd.AddCommand(oscCmd)
 
 	oscCmd.Flags().IntVar(&oscFlags.drive, "drive", 2, "the output drive strength in mA (2, 4, 6, 8)")
+	oscCmd.Flags().StringVar(&oscFlags.disableState, "disableState", "low", "the state of the outputs when disabled (low, high, highz, never)")
 	oscCmd.Flags().BoolVar(&oscFlags.intDiv, "intDiv", false, "use a fractional mutliplier with an integer divider (works only with output Clk0!)")
-	oscCmd.Flags().BoolVar(&oscFlags.noInit, "noInit", false, "do not initialize the Si5351")
+	oscCmd.Flags().BoolVar(&oscFlags.integerOnly, "integer", false, "use only even integer dividers for lower jitter")
+	oscCmd.Flags().StringVar(&oscFlags.tolerance, "tolerance", "0", "the acceptable deviation of the output frequencies, 0 for the closest possible frequencies")
+	oscCmd.Flags().StringVar(&oscFlags.spread, "spread", "", "enable spread spectrum on PLL A, down:<percent> or center:<percent> (requires integer dividers, e.g. with --intDiv or --integer)")
+	oscCmd.Flags().BoolVar(&oscFlags.noInit, "noInit", false, "do not initialize the Si5351, load its current state instead")
 }
 
 func runOsc(cmd *cobra.Command, args []string, device *si5351.Si5351) {
-	refFrequency := device.Crystal.Frequency()
-	log.Printf("Crystal @ %.2fHz", refFrequency)
-
 	drive := toOutputDrive(oscFlags.drive)
+	disableState, err := parseDisableState(oscFlags.disableState)
+	if err != nil {
+		log.Fatal(err)
+	}
+	spreadSpectrum, err := parseSpreadSpectrum(oscFlags.spread)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tolerance, err := parseFrequency(oscFlags.tolerance)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	if !oscFlags.noInit {
-		device.StartSetup()
+	if oscFlags.noInit {
+		if err := device.Load(); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		if err := device.StartSetup(); err != nil {
+			log.Fatal(err)
+		}
 	}
 
+	refFrequency := device.ReferenceFrequency(si5351.PLLA)
+	log.Printf("Reference @ %.2fHz", refFrequency)
+
 	if oscFlags.intDiv {
 		if len(args) != 1 {
 			log.Fatal("intDiv works only with one output")
@@ -52,23 +80,29 @@ func runOsc(cmd *cobra.Command, args []string, device *si5351.Si5351) {
 			log.Fatal(err)
 		}
 
-		multiplier, divider := si5351.FindFractionalMultiplierWithIntegerDivider(refFrequency, frequency)
-		device.PLLA().SetupMultiplier(multiplier)
+		multiplier, divider, err := si5351.FindFractionalMultiplierWithIntegerDivider(refFrequency, frequency)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := device.PLLA().SetupMultiplier(multiplier); err != nil {
+			log.Fatal(err)
+		}
 		pllFrequency := multiplier.Multiply(refFrequency)
 		log.Printf("PLLA @ %.2fHz: %v", pllFrequency, multiplier)
 
-		device.PrepareOutputs(si5351.PLLA, false, si5351.ClockInputMultisynth, drive, si5351.Clk0)
-		device.Clk0().SetupDivider(divider)
-		device.Clk0().SetIntegerMode(true)
+		if err := device.PrepareOutputs(si5351.PLLA, false, si5351.ClockInputMultisynth, drive, disableState, si5351.Clk0); err != nil {
+			log.Fatal(err)
+		}
+		if err := device.Clk0().SetupDivider(divider); err != nil {
+			log.Fatal(err)
+		}
 		outputFrequency := divider.Divide(pllFrequency)
 		log.Printf("Clk0 @ %.2fHz: %v", outputFrequency, divider)
 	} else {
-		f, _ := device.SetupPLL(si5351.PLLA, 900*si5351.MHz)
-		log.Printf("PLLA @ %.2fHz: %v", f, device.PLLA().Multiplier)
-
+		targets := make([]si5351.Target, 0, len(args))
 		for i, arg := range args {
 			output := si5351.OutputIndex(i)
-			if output > si5351.Clk5 {
+			if output > si5351.Clk7 {
 				break
 			}
 
@@ -76,15 +110,38 @@ func runOsc(cmd *cobra.Command, args []string, device *si5351.Si5351) {
 			if err != nil {
 				log.Fatal(err)
 			}
+			targets = append(targets, si5351.Target{Output: output, Frequency: frequency, Tolerance: tolerance, IntegerOnly: oscFlags.integerOnly})
+		}
 
-			device.PrepareOutputs(si5351.PLLA, false, si5351.ClockInputMultisynth, drive, output)
-			f, _ := device.SetOutputFrequency(output, frequency)
+		plan, err := device.PlanFrequencies(targets...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, p := range plan.PLLs {
+			if p.Used {
+				log.Printf("PLL%v @ %v: %v", si5351.PLLIndex(i), p.Tuning, p.Multiplier)
+			}
+		}
+		for _, o := range plan.Outputs {
+			if err := device.PrepareOutputs(o.PLL, false, si5351.ClockInputMultisynth, drive, disableState, o.Output); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Clk%d @ %v: PLL%v %v", o.Output, o.Tuning, o.PLL, o.Divider)
+		}
+		if err := device.ApplyPlan(plan); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-			log.Printf("Clk%d @ %.2fHz: %v", i, f, device.Clk0().FrequencyDivider)
+	if spreadSpectrum.Mode != si5351.SpreadSpectrumOff {
+		if err := device.SetupSpreadSpectrum(spreadSpectrum); err != nil {
+			log.Fatal(err)
 		}
 	}
 
 	if !oscFlags.noInit {
-		device.FinishSetup()
+		if err := device.FinishSetup(); err != nil {
+			log.Fatal(err)
+		}
 	}
 }
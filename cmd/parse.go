@@ -62,12 +62,92 @@ func parseOutput(s string) (si5351.OutputIndex, error) {
 	if err != nil {
 		return 0, err
 	}
-	if i < int(si5351.Clk0) || i > int(si5351.Clk5) {
-		return 0, errors.Errorf("invalid output %s, only outputs 0-5 supported", s)
+	if i < int(si5351.Clk0) || i > int(si5351.Clk7) {
+		return 0, errors.Errorf("invalid output %s, only outputs 0-7 supported", s)
 	}
 	return si5351.OutputIndex(i), nil
 }
 
+func parseDisableState(s string) (si5351.OutputDisableState, error) {
+	switch strings.ToLower(s) {
+	case "low":
+		return si5351.OutputDisableLow, nil
+	case "high":
+		return si5351.OutputDisableHigh, nil
+	case "highz":
+		return si5351.OutputDisableHighZ, nil
+	case "never":
+		return si5351.OutputDisableNever, nil
+	default:
+		return si5351.OutputDisableLow, errors.Errorf("invalid disable state %s, try low, high, highz, or never", s)
+	}
+}
+
+func parseSpreadSpectrum(s string) (si5351.SpreadSpectrum, error) {
+	if s == "" {
+		return si5351.SpreadSpectrum{Mode: si5351.SpreadSpectrumOff}, nil
+	}
+	values := strings.Split(s, ":")
+	if len(values) != 2 {
+		return si5351.SpreadSpectrum{}, errors.Errorf("invalid spread spectrum %s, try down:<percent> or center:<percent>", s)
+	}
+
+	var result si5351.SpreadSpectrum
+	switch strings.ToLower(strings.TrimSpace(values[0])) {
+	case "down":
+		result.Mode = si5351.SpreadSpectrumDown
+	case "center":
+		result.Mode = si5351.SpreadSpectrumCenter
+	default:
+		return si5351.SpreadSpectrum{}, errors.Errorf("invalid spread spectrum mode %s, try down or center", values[0])
+	}
+	amplitude, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(values[1]), "%"), 64)
+	if err != nil {
+		return si5351.SpreadSpectrum{}, err
+	}
+	result.Amplitude = amplitude
+	return result, nil
+}
+
+func parseFanoutSource(s string) (si5351.ClockInputSource, error) {
+	switch strings.ToLower(s) {
+	case "xtal":
+		return si5351.ClockInputCrystal, nil
+	case "clkin":
+		return si5351.ClockInputClkin, nil
+	case "ms":
+		return si5351.ClockInputSharedMultisynth, nil
+	default:
+		return 0, errors.Errorf("invalid fanout source %s, try xtal, clkin, or ms", s)
+	}
+}
+
+func parseExternalQuadratureDivider(d int) (si5351.ExternalQuadratureDivider, error) {
+	switch d {
+	case 0:
+		return si5351.NoExternalQuadratureDivider, nil
+	case 2:
+		return si5351.QuadratureBy2, nil
+	case 4:
+		return si5351.QuadratureBy4, nil
+	default:
+		return 0, errors.Errorf("invalid external quadrature divider %d, try 0, 2, or 4", d)
+	}
+}
+
+func parseTuningStrategy(s string) (si5351.TuningStrategy, error) {
+	switch strings.ToLower(s) {
+	case "fixed":
+		return si5351.FixedPLLStrategy{}, nil
+	case "integer":
+		return si5351.IntegerDividerStrategy{}, nil
+	case "hybrid":
+		return si5351.HybridStrategy{}, nil
+	default:
+		return nil, errors.Errorf("invalid tuning strategy %s, try fixed, integer, or hybrid", s)
+	}
+}
+
 func toCrystalFrequency(f int) si5351.Frequency {
 	switch f {
 	case 27:
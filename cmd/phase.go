@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ftl/si5351/pkg/si5351"
+)
+
+var phaseFlags = struct {
+	drive        int
+	disableState string
+	noInit       bool
+	lowPLL       bool
+}{}
+
+var phaseCmd = &cobra.Command{
+	Use:   "phase [frequency] [phase1] [phase2] [phase3] [phase4] [phase5]",
+	Short: "Output the given frequency on CLK0 and on CLK1-CLK5 with the given phase offsets in degrees relative to CLK0",
+	Long: `Output the given frequency on CLK0 and on CLK1-CLK5 with the given phase offsets in degrees relative to CLK0.
+All outputs use PLL A and the same even integer divider of at most 126, like quadrature signals, because the phase offset
+register holds at most 127 steps. Therefore the frequency must be within 4.762MHz and 200MHz, with --lowPLL the PLL may run
+below its specified range of 600-900MHz, like with the quad command. The range of the phase offsets depends on the frequency.
+
+Example: phase 10M 90 180 270 # output 10MHz on CLK0-CLK3 with 0°, 90°, 180°, and 270°
+`,
+	Run: runSi5351(runPhase),
+}
+
+func init() {
+	rootCmd.AddCommand(phaseCmd)
+
+	phaseCmd.Flags().IntVar(&phaseFlags.drive, "drive", 2, "the output drive strength in mA (2, 4, 6, 8)")
+	phaseCmd.Flags().StringVar(&phaseFlags.disableState, "disableState", "low", "the state of the outputs when disabled (low, high, highz, never)")
+	phaseCmd.Flags().BoolVar(&phaseFlags.noInit, "noInit", false, "do not initialize the Si5351, load its current state instead")
+	phaseCmd.Flags().BoolVar(&phaseFlags.lowPLL, "lowPLL", false, "allow PLL frequencies below 600MHz for lower frequencies")
+}
+
+func runPhase(cmd *cobra.Command, args []string, device *si5351.Si5351) {
+	if len(args) < 2 || len(args) > 6 {
+		log.Fatal("wrong number of arguments, try phase --help")
+	}
+
+	frequency, err := parseFrequency(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	disableState, err := parseDisableState(phaseFlags.disableState)
+	if err != nil {
+		log.Fatal(err)
+	}
+	drive := toOutputDrive(phaseFlags.drive)
+
+	outputs := []si5351.OutputIndex{si5351.Clk0}
+	offsets := make([]si5351.PhaseOffset, 0, len(args)-1)
+	for i, arg := range args[1:] {
+		degrees, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+		output := si5351.OutputIndex(i + 1)
+		outputs = append(outputs, output)
+		offsets = append(offsets, si5351.PhaseOffset{Output: output, Degrees: degrees})
+	}
+
+	if phaseFlags.noInit {
+		if err := device.Load(); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		if err := device.StartSetup(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// the divider of a phase group is limited like the divider of a quadrature signal
+	refFrequency := device.ReferenceFrequency(si5351.PLLA)
+	minPLLFrequency := si5351.MinPLLFrequency
+	if phaseFlags.lowPLL {
+		minPLLFrequency = si5351.MinPLLMultiplier * refFrequency
+	}
+	multiplier, divider, err := si5351.FindQuadratureRatios(refFrequency, frequency, minPLLFrequency)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := device.PrepareOutputs(si5351.PLLA, false, si5351.ClockInputMultisynth, drive, disableState, outputs...); err != nil {
+		log.Fatal(err)
+	}
+	if err := device.PLLA().SetupMultiplier(multiplier); err != nil {
+		log.Fatal(err)
+	}
+	for _, output := range outputs {
+		if err := device.FractionalOutput(output).SetupDivider(divider); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	phases, err := device.SetupPhaseGroup(si5351.Clk0, offsets...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tuning := si5351.Tuning{Requested: frequency, Achieved: divider.DivideExact(multiplier.MultiplyExact(device.ExactReferenceFrequency(si5351.PLLA)))}
+	for _, phase := range phases {
+		log.Printf("Clk%d @ %v: %.2f° (%.0fps)", phase.Output, tuning, phase.Degrees, phase.Picoseconds)
+	}
+
+	if !phaseFlags.noInit {
+		if err := device.FinishSetup(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
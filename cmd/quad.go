@@ -9,21 +9,37 @@ import (
 )
 
 var quadFlags = struct {
-	drive  int
-	noInit bool
+	drive        int
+	disableState string
+	noInit       bool
+	lowPLL       bool
+	external     int
 }{}
 
 var quadCmd = &cobra.Command{
 	Use:   "quad [pll] [i output] [q output] [frequency]",
 	Short: "Output the given frequency on the two given outputs with a phase shift of 90°, using the given PLL.",
-	Run:   runSi5351(runQuad),
+	Long: `Output the given frequency on the two given outputs with a phase shift of 90°, using the given PLL.
+Quadrature signals are available from 4.762MHz up to 200MHz. With --lowPLL, the PLL may run below its specified
+range of 600-900MHz, which extends the range down to 15 times the reference frequency divided by 126 (2.976MHz with a 25MHz crystal).
+Below this range, the Si5351 cannot shift the phase by 90°. With --external 2 or --external 4, lower frequencies are
+generated with an external divider: the i output carries a LO at 2 or 4 times the frequency, the q output carries
+the inverted LO. An external divider by 2 (two flip-flops, clocked by both outputs) or by 4 (a Johnson counter, clocked
+by the i output) covers the full HF range from 1.8MHz upwards.
+
+Example: quad --external 4 A 0 1 1840k # output a LO at 7.36MHz for an external divider by 4
+`,
+	Run: runSi5351(runQuad),
 }
 
 func init() {
 	rootCmd.AddCommand(quadCmd)
 
 	quadCmd.Flags().IntVar(&quadFlags.drive, "drive", 2, "the output drive strength in mA (2, 4, 6, 8)")
-	quadCmd.Flags().BoolVar(&quadFlags.noInit, "noInit", false, "do not initialize the Si5351")
+	quadCmd.Flags().StringVar(&quadFlags.disableState, "disableState", "low", "the state of the outputs when disabled (low, high, highz, never)")
+	quadCmd.Flags().BoolVar(&quadFlags.lowPLL, "lowPLL", false, "allow PLL frequencies below 600MHz for lower quadrature frequencies")
+	quadCmd.Flags().BoolVar(&quadFlags.noInit, "noInit", false, "do not initialize the Si5351, load its current state instead")
+	quadCmd.Flags().IntVar(&quadFlags.external, "external", 0, "the ratio of an external quadrature divider for lower frequencies (0, 2, 4)")
 }
 
 func runQuad(cmd *cobra.Command, args []string, device *si5351.Si5351) {
@@ -31,25 +47,57 @@ func runQuad(cmd *cobra.Command, args []string, device *si5351.Si5351) {
 		log.Fatal("wrong number of arguments, try quad --help")
 	}
 
-	var err error
 	pll, err := parsePLL(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
 	iOutput, err := parseOutput(args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
 	qOutput, err := parseOutput(args[2])
+	if err != nil {
+		log.Fatal(err)
+	}
 	frequency, err := parseFrequency(args[3])
-	drive := toOutputDrive(quadFlags.drive)
-
 	if err != nil {
 		log.Fatal(err)
 	}
+	disableState, err := parseDisableState(quadFlags.disableState)
+	if err != nil {
+		log.Fatal(err)
+	}
+	external, err := parseExternalQuadratureDivider(quadFlags.external)
+	if err != nil {
+		log.Fatal(err)
+	}
+	drive := toOutputDrive(quadFlags.drive)
 
-	if !quadFlags.noInit {
-		device.StartSetup()
+	if quadFlags.noInit {
+		if err := device.Load(); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		if err := device.StartSetup(); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	device.PrepareOutputs(pll, false, si5351.ClockInputMultisynth, drive, iOutput, qOutput)
-	device.SetupQuadratureOutput(pll, iOutput, qOutput, frequency)
+	if err := device.PrepareOutputs(pll, false, si5351.ClockInputMultisynth, drive, disableState, iOutput, qOutput); err != nil {
+		log.Fatal(err)
+	}
+	device.AllowLowPLLFrequency = quadFlags.lowPLL
+	device.ExternalQuadratureDivider = external
+	pllTuning, outputTuning, err := device.SetupQuadratureOutput(pll, iOutput, qOutput, frequency)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("PLL%v @ %v", pll, pllTuning)
+	log.Printf("Clk%d/Clk%d @ %v", iOutput, qOutput, outputTuning)
 
 	if !quadFlags.noInit {
-		device.FinishSetup()
+		if err := device.FinishSetup(); err != nil {
+			log.Fatal(err)
+		}
 	}
 }
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"log"
+	"strings"
+	"time"
 
 	"github.com/ftl/i2c"
 	"github.com/spf13/cobra"
@@ -14,10 +16,14 @@ var cfgFile string
 var rootFlags = struct {
 	address     uint8
 	bus         int
+	variant     string
 	debugI2C    bool
 	crystalFreq int
 	crystalLoad int
 	ppm         int
+	lockTimeout time.Duration
+	clkin       string
+	clkinPLLs   string
 }{}
 
 var rootCmd = &cobra.Command{
@@ -35,28 +41,64 @@ func Execute() {
 func init() {
 	rootCmd.PersistentFlags().Uint8Var(&rootFlags.address, "address", si5351.DefaultI2CAddress, "the I2C address of the Si5351")
 	rootCmd.PersistentFlags().IntVar(&rootFlags.bus, "bus", 1, "the I2C bus number to which the Si5351 is attached to")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.variant, "variant", si5351.Si5351A20QFN.Name, "the variant of the Si5351 ("+variantNames()+")")
 	rootCmd.PersistentFlags().BoolVar(&rootFlags.debugI2C, "debugI2C", false, "enable debug output of the communication on the I2C bus")
 	rootCmd.PersistentFlags().IntVar(&rootFlags.crystalFreq, "crystalFreq", 25, "the frequency of the crystal in MHz (25, 27)")
 	rootCmd.PersistentFlags().IntVar(&rootFlags.crystalLoad, "crystalLoad", 10, "the internal capacitive load of the crystal in pF (6, 8, 10)")
 	rootCmd.PersistentFlags().IntVar(&rootFlags.ppm, "ppm", 0, "the frequency correction of the crystal in PPM")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.clkin, "clkin", "", "the frequency of the reference clock on the CLKIN input (10M-100M, Si5351C only)")
+	rootCmd.PersistentFlags().StringVar(&rootFlags.clkinPLLs, "clkinPLLs", "AB", "the PLLs that use CLKIN as reference (A, B, AB)")
+	rootCmd.PersistentFlags().DurationVar(&rootFlags.lockTimeout, "lockTimeout", 0, "wait up to this duration for the PLLs to lock after a reset (0 = do not wait)")
 }
 
 func runSi5351(f func(cmd *cobra.Command, args []string, device *si5351.Si5351)) func(cmd *cobra.Command, args []string) {
 	return func(cmd *cobra.Command, args []string) {
+		variant, ok := si5351.FindVariant(rootFlags.variant)
+		if !ok {
+			log.Fatalf("unknown variant %s, try one of %s", rootFlags.variant, variantNames())
+		}
 		crystal := si5351.Crystal{BaseFrequency: toCrystalFrequency(rootFlags.crystalFreq), Load: toCrystalLoad(rootFlags.crystalLoad), CorrectionPPM: rootFlags.ppm}
-		bus, err := i2c.Open(rootFlags.address, rootFlags.bus)
+		bus, err := si5351.OpenI2C(rootFlags.address, rootFlags.bus)
 		if err != nil {
 			log.Fatal(err)
 		}
 		defer bus.Close()
 		i2c.Debug = rootFlags.debugI2C
 
-		device := si5351.New(crystal, bus)
+		device := si5351.New(variant, crystal, bus)
+		device.LockTimeout = rootFlags.lockTimeout
+
+		if rootFlags.clkin != "" {
+			clkinFrequency, err := parseFrequency(rootFlags.clkin)
+			if err != nil {
+				log.Fatal(err)
+			}
+			plls := make([]si5351.PLLIndex, 0, len(rootFlags.clkinPLLs))
+			for _, c := range rootFlags.clkinPLLs {
+				pll, err := parsePLL(string(c))
+				if err != nil {
+					log.Fatal(err)
+				}
+				plls = append(plls, pll)
+			}
+			if err := device.SetupClkin(si5351.Clkin{BaseFrequency: clkinFrequency}, plls...); err != nil {
+				log.Fatal(err)
+			}
+		}
 
 		f(cmd, args, device)
 
-		if bus.Err() != nil {
+		// the commands check the errors of their accesses, the bus only reports a failed reopen of the device
+		if err := bus.Err(); err != nil {
 			log.Fatal(err)
 		}
 	}
 }
+
+func variantNames() string {
+	names := make([]string, len(si5351.Variants))
+	for i, variant := range si5351.Variants {
+		names[i] = variant.Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"log"
+
 	"github.com/spf13/cobra"
 
 	"github.com/ftl/si5351/pkg/si5351"
@@ -17,5 +19,7 @@ func init() {
 }
 
 func runShutdown(cmd *cobra.Command, args []string, device *si5351.Si5351) {
-	device.Shutdown()
+	if err := device.Shutdown(); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ftl/si5351/pkg/si5351"
+)
+
+var statusFlags = struct {
+	clear bool
+}{}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the device status and the sticky interrupt status of the Si5351",
+	Run:   runSi5351(runStatus),
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().BoolVar(&statusFlags.clear, "clear", false, "clear the sticky interrupt status after reading it")
+}
+
+func runStatus(cmd *cobra.Command, args []string, device *si5351.Si5351) {
+	status, err := device.Status()
+	if err != nil {
+		log.Fatal(err)
+	}
+	sticky, err := device.StickyStatus()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("revision:           %d\n", status.Revision)
+	fmt.Printf("                    current sticky\n")
+	printStatusFlag("system init", status.SystemInit, sticky.SystemInit)
+	printStatusFlag("loss of lock A", status.LossOfLockA, sticky.LossOfLockA)
+	printStatusFlag("loss of lock B", status.LossOfLockB, sticky.LossOfLockB)
+	printStatusFlag("loss of CLKIN", status.LossOfSignalClkin, sticky.LossOfSignalClkin)
+	printStatusFlag("loss of crystal", status.LossOfSignalCrystal, sticky.LossOfSignalCrystal)
+
+	if statusFlags.clear {
+		if err := device.ClearStickyStatus(si5351.AllStatusBits); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func printStatusFlag(name string, current, sticky bool) {
+	fmt.Printf("%-19s %-7t %t\n", name+":", current, sticky)
+}
@@ -28,13 +28,22 @@ func init() {
 func runTest(cmd *cobra.Command, args []string, device *si5351.Si5351) {
 	fmt.Printf("testing Si5351 @ 0x%x on I2C bus #%d %s\n", rootFlags.address, rootFlags.bus, testFlags.test)
 
-	device.StartSetup()
-
-	device.PrepareOutputs(si5351.PLLA, false, si5351.ClockInputMultisynth, si5351.OutputDrive2mA, si5351.Clk0, si5351.Clk1)
-	fpll, fout, _ := device.SetupQuadratureOutput(si5351.PLLA, si5351.Clk0, si5351.Clk1, 30*si5351.MHz)
-
-	device.FinishSetup()
-
-	log.Printf("PLLA @ %.2fHz", fpll)
-	log.Printf("Clk0 @ %.2fHz", fout)
+	if err := device.StartSetup(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := device.PrepareOutputs(si5351.PLLA, false, si5351.ClockInputMultisynth, si5351.OutputDrive2mA, si5351.OutputDisableLow, si5351.Clk0, si5351.Clk1); err != nil {
+		log.Fatal(err)
+	}
+	fpll, fout, err := device.SetupQuadratureOutput(si5351.PLLA, si5351.Clk0, si5351.Clk1, 30*si5351.MHz)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := device.FinishSetup(); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("PLLA @ %v", fpll)
+	log.Printf("Clk0 @ %v", fout)
 }
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ftl/si5351/pkg/si5351"
+)
+
+var tuneFlags = struct {
+	strategy string
+}{}
+
+var tuneCmd = &cobra.Command{
+	Use:   "tune [output] [frequency]",
+	Short: "Retune the given output of a running Si5351 to the given frequency",
+	Long: `Retune the given output of a running Si5351 to the given frequency. The current state is loaded from the device,
+set up the output first, e.g. with osc. Only the changed bytes of the parameters are written, the PLL is only reset if the
+tuning strategy requires it.
+
+Tuning strategies:
+fixed: keep the PLL frequency, tune the fractional Multisynth of the output (fast, e.g. for FSK)
+integer: use an even integer Multisynth and move the PLL, reset the PLL whenever the divider changes
+hybrid: keep the even integer Multisynth across a band and move only the PLL (low phase noise, e.g. for receivers)
+`,
+	Run: runSi5351(runTune),
+}
+
+func init() {
+	rootCmd.AddCommand(tuneCmd)
+
+	tuneCmd.Flags().StringVar(&tuneFlags.strategy, "strategy", "fixed", "the tuning strategy (fixed, integer, hybrid)")
+}
+
+func runTune(cmd *cobra.Command, args []string, device *si5351.Si5351) {
+	if len(args) != 2 {
+		log.Fatal("wrong number of arguments, try tune --help")
+	}
+
+	output, err := parseOutput(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	frequency, err := parseFrequency(args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	strategy, err := parseTuningStrategy(tuneFlags.strategy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := device.Load(); err != nil {
+		log.Fatal(err)
+	}
+	device.TuningStrategy = strategy
+	tuning, err := device.Tune(output, frequency)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Clk%d @ %v", output, tuning)
+}
@@ -0,0 +1,36 @@
+package si5351
+
+import "fmt"
+
+// The limits of the CLKIN frequency and of the reference frequency of the PLLs.
+const (
+	MinClkinFrequency     = 10 * MHz
+	MaxClkinFrequency     = 100 * MHz
+	MinReferenceFrequency = 10 * MHz
+	MaxReferenceFrequency = 40 * MHz
+)
+
+// Clkin represents the external reference clock on the CLKIN input of the Si5351C.
+type Clkin struct {
+	BaseFrequency Frequency
+	CorrectionPPM int
+}
+
+// Frequency is the corrected frequency of this reference clock.
+func (c Clkin) Frequency() Frequency {
+	return Frequency(float64(c.BaseFrequency) + ((float64(c.CorrectionPPM) / 1000000.0) * float64(c.BaseFrequency)))
+}
+
+// FindClkinInputDivider finds the smallest CLKIN input divider that brings the given CLKIN frequency into the range
+// of the PLL's reference frequency.
+func FindClkinInputDivider(frequency Frequency) (ClockDivider, error) {
+	if frequency < MinClkinFrequency || frequency > MaxClkinFrequency {
+		return ClockBy1, fmt.Errorf("invalid CLKIN frequency %.0fHz, must be within %.0fHz-%.0fHz", frequency, MinClkinFrequency, MaxClkinFrequency)
+	}
+	for divider := ClockBy1; divider <= ClockBy8; divider++ {
+		if frequency/Frequency(divider.Factor()) <= MaxReferenceFrequency {
+			return divider, nil
+		}
+	}
+	return ClockBy8, nil
+}
@@ -0,0 +1,66 @@
+package si5351
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindClkinInputDivider(t *testing.T) {
+	testCases := []struct {
+		frequency Frequency
+		expected  ClockDivider
+		valid     bool
+	}{
+		{9 * MHz, ClockBy1, false},
+		{10 * MHz, ClockBy1, true},
+		{40 * MHz, ClockBy1, true},
+		{50 * MHz, ClockBy2, true},
+		{80 * MHz, ClockBy2, true},
+		{100 * MHz, ClockBy4, true},
+		{101 * MHz, ClockBy1, false},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%.0f", tc.frequency), func(t *testing.T) {
+			actual, err := FindClkinInputDivider(tc.frequency)
+			if tc.valid {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, actual)
+				reference := tc.frequency / Frequency(actual.Factor())
+				assert.True(t, reference >= MinReferenceFrequency && reference <= MaxReferenceFrequency)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestSetupClkin(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351C, Crystal{BaseFrequency: Crystal25MHz}, bus)
+
+	err := device.SetupClkin(Clkin{BaseFrequency: 50 * MHz}, PLLB)
+
+	assert.NoError(t, err)
+	assert.Equal(t, ClockBy2, device.InputDivider)
+	assert.Equal(t, PLLInputCrystal, device.PLLA().InputSource)
+	assert.Equal(t, PLLInputClkin, device.PLLB().InputSource)
+	assert.Equal(t, byte(0x48), bus.registers[RegPLLInputSource])
+	assert.Equal(t, 25*MHz, device.ReferenceFrequency(PLLA))
+	assert.Equal(t, 25*MHz, device.ReferenceFrequency(PLLB))
+
+	err = device.SetupClkin(Clkin{BaseFrequency: 10 * MHz}, PLLA, PLLB)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 10*MHz, device.ReferenceFrequency(PLLA))
+	f, err := device.SetupPLL(PLLA, 900*MHz)
+	assert.NoError(t, err)
+	assert.Equal(t, 900*MHz, f.Frequency())
+	assert.Equal(t, FractionalRatio{A: 90, B: 0, C: 1}, device.PLLA().Multiplier)
+
+	loaded := New(Si5351C, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	loaded.Load()
+	assert.Equal(t, ClockBy1, loaded.InputDivider)
+	assert.Equal(t, PLLInputClkin, loaded.PLLA().InputSource)
+}
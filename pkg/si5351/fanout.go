@@ -0,0 +1,149 @@
+package si5351
+
+import "fmt"
+
+// Fanout describes which clock sources are fanned out directly to the outputs.
+type Fanout struct {
+	Crystal    bool
+	Clkin      bool
+	Multisynth bool
+}
+
+func decodeFanout(value byte) Fanout {
+	return Fanout{
+		Crystal:    value&(1<<6) != 0,
+		Clkin:      value&(1<<7) != 0,
+		Multisynth: value&(1<<4) != 0,
+	}
+}
+
+func (f Fanout) encode() byte {
+	var result byte
+	if f.Clkin {
+		result |= 1 << 7
+	}
+	if f.Crystal {
+		result |= 1 << 6
+	}
+	if f.Multisynth {
+		result |= 1 << 4
+	}
+	return result
+}
+
+// SharedMultisynth returns the output whose Multisynth is used by the given output with ClockInputSharedMultisynth:
+// CLK0 for CLK1-CLK3 and CLK4 for CLK5-CLK7.
+func SharedMultisynth(output OutputIndex) (OutputIndex, error) {
+	switch output {
+	case Clk1, Clk2, Clk3:
+		return Clk0, nil
+	case Clk5, Clk6, Clk7:
+		return Clk4, nil
+	default:
+		return 0, fmt.Errorf("CLK%d cannot use a shared Multisynth", output)
+	}
+}
+
+// allFanouts selects the bits of all fanouts in the fanout enable register, the other bits are reserved.
+var allFanouts = Fanout{Crystal: true, Clkin: true, Multisynth: true}
+
+// SetupFanout writes the fanout configuration into the fanout enable register.
+func (s *Si5351) SetupFanout(fanout Fanout) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.setupFanout(fanout)
+}
+
+func (s *Si5351) setupFanout(fanout Fanout) error {
+	if fanout.Clkin {
+		if err := s.Variant.checkInput(InputClkin); err != nil {
+			return err
+		}
+	}
+
+	if err := s.shared.modify(s.bus, RegFanoutEnable, allFanouts.encode(), fanout.encode()); err != nil {
+		return err
+	}
+	s.Fanout = fanout
+	return nil
+}
+
+// enableFanoutFor enables the fanout that is needed for the given input source. Only the bit of this fanout is changed,
+// the other fanouts keep their state on the device.
+func (s *Si5351) enableFanoutFor(inputSource ClockInputSource) error {
+	var fanout Fanout
+	switch inputSource {
+	case ClockInputCrystal:
+		fanout.Crystal = true
+	case ClockInputClkin:
+		if err := s.Variant.checkInput(InputClkin); err != nil {
+			return err
+		}
+		fanout.Clkin = true
+	case ClockInputSharedMultisynth:
+		fanout.Multisynth = true
+	default:
+		return nil
+	}
+
+	mask := fanout.encode()
+	value, err := s.shared.get(s.bus, RegFanoutEnable)
+	if err != nil {
+		return err
+	}
+	if value&mask != 0 {
+		return nil
+	}
+	if err := s.shared.modify(s.bus, RegFanoutEnable, mask, mask); err != nil {
+		return err
+	}
+	s.Fanout = decodeFanout(value | mask)
+	return nil
+}
+
+// SetupFanoutOutput sets up the given output to provide a buffered copy of the given input source: the crystal,
+// CLKIN, or the shared Multisynth (see SharedMultisynth). The corresponding fanout is enabled. With the shared Multisynth
+// as input source, the output uses the PLL of the output that owns the Multisynth.
+func (s *Si5351) SetupFanoutOutput(output OutputIndex, inputSource ClockInputSource, invert bool, drive OutputDrive) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.setupFanoutOutput(output, inputSource, invert, drive)
+}
+
+func (s *Si5351) setupFanoutOutput(output OutputIndex, inputSource ClockInputSource, invert bool, drive OutputDrive) error {
+	if err := s.Variant.checkOutput(output); err != nil {
+		return err
+	}
+
+	o := s.Output(output)
+	pll := o.PLL
+	switch inputSource {
+	case ClockInputCrystal:
+	case ClockInputClkin:
+		if err := s.Variant.checkInput(InputClkin); err != nil {
+			return err
+		}
+	case ClockInputSharedMultisynth:
+		source, err := SharedMultisynth(output)
+		if err != nil {
+			return err
+		}
+		pll = s.Output(source).PLL
+	default:
+		return fmt.Errorf("invalid fanout input source %d", inputSource)
+	}
+
+	if err := s.enableFanoutFor(inputSource); err != nil {
+		return err
+	}
+	return o.setupControl(false, false, pll, invert, inputSource, drive)
+}
+
+// SetupDifferentialOutput sets up the given output to provide the inverted signal of the shared Multisynth
+// (see SharedMultisynth). Together with the output that owns the Multisynth, this forms a differential pair,
+// e.g. CLK0 and CLK1, without using an additional Multisynth.
+func (s *Si5351) SetupDifferentialOutput(output OutputIndex, drive OutputDrive) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.setupFanoutOutput(output, ClockInputSharedMultisynth, true, drive)
+}
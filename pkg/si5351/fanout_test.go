@@ -0,0 +1,74 @@
+package si5351
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetupDifferentialOutput(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.SetupPLL(PLLB, 900*MHz)
+	device.PrepareOutputs(PLLB, false, ClockInputMultisynth, OutputDrive8mA, OutputDisableLow, Clk0)
+
+	err := device.SetupDifferentialOutput(Clk1, OutputDrive8mA)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Fanout{Multisynth: true}, device.Fanout)
+	assert.Equal(t, byte(0x10), bus.registers[RegFanoutEnable])
+	assert.Equal(t, ClockInputSharedMultisynth, device.Clk1().InputSource)
+	assert.True(t, device.Clk1().Invert)
+	assert.Equal(t, PLLB, device.Clk1().PLL)
+	assert.Equal(t, byte(0x3B), bus.registers[RegClk1Control])
+
+	assert.Error(t, device.SetupDifferentialOutput(Clk4, OutputDrive8mA))
+}
+
+func TestSetupFanoutOutput(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.SetupFanout(Fanout{Multisynth: true})
+
+	err := device.SetupFanoutOutput(Clk2, ClockInputCrystal, false, OutputDrive2mA)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Fanout{Crystal: true, Multisynth: true}, device.Fanout)
+	assert.Equal(t, byte(0x50), bus.registers[RegFanoutEnable])
+	assert.Equal(t, ClockInputCrystal, device.Clk2().InputSource)
+
+	err = device.SetupFanoutOutput(Clk2, ClockInputClkin, false, OutputDrive2mA)
+	assert.True(t, errors.Is(err, ErrUnsupportedInput))
+
+	loaded := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	loaded.Load()
+	assert.Equal(t, device.Fanout, loaded.Fanout)
+}
+
+func TestPrepareOutputsEnablesFanout(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351C, Crystal{BaseFrequency: Crystal25MHz}, bus)
+
+	err := device.PrepareOutputs(PLLA, false, ClockInputClkin, OutputDrive2mA, OutputDisableLow, Clk3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Fanout{Clkin: true}, device.Fanout)
+	assert.Equal(t, byte(0x80), bus.registers[RegFanoutEnable])
+}
+
+func TestEnableFanoutWithoutLoad(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	bus.registers[RegFanoutEnable] = 0x10
+
+	err := device.PrepareOutputs(PLLA, false, ClockInputCrystal, OutputDrive2mA, OutputDisableLow, Clk2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x50), bus.registers[RegFanoutEnable])
+	assert.Equal(t, Fanout{Crystal: true, Multisynth: true}, device.Fanout)
+
+	err = device.SetupFanout(Fanout{})
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x00), bus.registers[RegFanoutEnable])
+}
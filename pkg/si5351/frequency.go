@@ -1,7 +1,10 @@
 package si5351
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"math/big"
 )
 
 // Frequency represents a frequency in Hz
@@ -14,6 +17,52 @@ const (
 	MHz Frequency = 1000000
 )
 
+// The errors that indicate that a frequency is out of range.
+var (
+	ErrPLLOutOfRange    = errors.New("PLL frequency out of range")
+	ErrOutputOutOfRange = errors.New("output frequency out of range")
+)
+
+// ErrInvalidRatio indicates that the parameters of a ratio in the Si5351's registers do not describe a valid ratio.
+var ErrInvalidRatio = errors.New("invalid ratio parameters")
+
+// RangeError indicates that a frequency is out of the range of a PLL, an output, or one of their dividers.
+type RangeError struct {
+	What      string
+	Frequency Frequency
+	Min       Frequency
+	Max       Frequency
+	Err       error
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("%.0fHz is out of the range of the %s, must be within %.0fHz-%.0fHz", e.Frequency, e.What, e.Min, e.Max)
+}
+
+// Unwrap returns ErrPLLOutOfRange or ErrOutputOutOfRange.
+func (e *RangeError) Unwrap() error {
+	return e.Err
+}
+
+// checkPLLFrequency checks if the given frequency is within the range of the PLLs (600-900MHz).
+func checkPLLFrequency(frequency Frequency) error {
+	if frequency < MinPLLFrequency || frequency > MaxPLLFrequency {
+		return &RangeError{What: "PLL", Frequency: frequency, Min: MinPLLFrequency, Max: MaxPLLFrequency, Err: ErrPLLOutOfRange}
+	}
+	return nil
+}
+
+// checkOutputFrequency checks if the given frequency is within the range of the outputs (2.5kHz-200MHz).
+func checkOutputFrequency(frequency Frequency) error {
+	if frequency < MinOutputFrequency || frequency > MaxOutputFrequency {
+		return &RangeError{What: "outputs", Frequency: frequency, Min: MinOutputFrequency, Max: MaxOutputFrequency, Err: ErrOutputOutOfRange}
+	}
+	return nil
+}
+
+// maxDenominator is the largest denominator that fits into the 20 bits of the P3 parameter.
+const maxDenominator = 0xFFFFF
+
 // ClockDivider represents a clock divider used at several places to divide a clock by a multiple of two.
 type ClockDivider uint8
 
@@ -50,7 +99,7 @@ func (d *FractionalRatio) Encode() (p1, p2, p3 uint32) {
 		fraction = 0
 		p3 = 1
 	} else {
-		fraction = uint32(128.0 * (float64(d.B) / float64(d.C)))
+		fraction = (128 * d.B) / d.C
 		p3 = d.C
 	}
 	p1 = 128*d.A + fraction - 512
@@ -58,7 +107,7 @@ func (d *FractionalRatio) Encode() (p1, p2, p3 uint32) {
 	return
 }
 
-// Multiply this ration with the given base frequency.
+// Multiply this ration with the given base frequency. The result is rounded, use MultiplyExact to get the exact value.
 func (d *FractionalRatio) Multiply(base Frequency) Frequency {
 	if d.C == 0 {
 		return base * Frequency(d.A)
@@ -66,7 +115,7 @@ func (d *FractionalRatio) Multiply(base Frequency) Frequency {
 	return Frequency(float64(base) * (float64(d.A) + float64(d.B)/float64(d.C)))
 }
 
-// Divide the given frequency by this ratio.
+// Divide the given frequency by this ratio. The result is rounded, use DivideExact to get the exact value.
 func (d *FractionalRatio) Divide(base Frequency) Frequency {
 	if d.C == 0 {
 		return base / (Frequency(d.A) * Frequency(d.ClockDivider.Factor()))
@@ -74,6 +123,11 @@ func (d *FractionalRatio) Divide(base Frequency) Frequency {
 	return Frequency(float64(base) / ((float64(d.A) + (float64(d.B) / float64(d.C))) * float64(d.ClockDivider.Factor())))
 }
 
+// IsZero indicates if this is the zero ratio, e.g. of a PLL or a Multisynth that is not configured.
+func (d *FractionalRatio) IsZero() bool {
+	return d.A == 0 && d.B == 0
+}
+
 // IsInteger indicates if this divider can be used in integer mode.
 func (d *FractionalRatio) IsInteger() bool {
 	return (d.A%2 == 0) && (d.B == 0)
@@ -81,21 +135,100 @@ func (d *FractionalRatio) IsInteger() bool {
 
 // Bytes returns the representation of this divider in the Si5351's registers as bytes.
 func (d *FractionalRatio) Bytes() []byte {
+	bytes := make([]byte, 8)
+	d.PutBytes(bytes)
+	return bytes
+}
+
+// PutBytes writes the representation of this divider in the Si5351's registers into the given slice,
+// which must have a length of at least 8 bytes. PutBytes does not allocate.
+func (d *FractionalRatio) PutBytes(bytes []byte) {
 	p1, p2, p3 := d.Encode()
-	bytes := []byte{
-		byte((p3 & 0x0000FF00) >> 8),
-		byte(p3 & 0x000000FF),
-		byte((p1&0x00030000)>>16) | byte(d.ClockDivider<<4),
-		byte((p1 & 0x0000FF00) >> 8),
-		byte(p1 & 0x000000FF),
-		byte((p3&0x000F0000)>>12) | byte((p2&0x000F0000)>>16),
-		byte((p2 & 0x0000FF00) >> 8),
-		byte(p2 & 0x000000FF),
-	}
+	bytes[0] = byte((p3 & 0x0000FF00) >> 8)
+	bytes[1] = byte(p3 & 0x000000FF)
+	bytes[2] = byte((p1&0x00030000)>>16) | byte(d.ClockDivider<<4)
+	bytes[3] = byte((p1 & 0x0000FF00) >> 8)
+	bytes[4] = byte(p1 & 0x000000FF)
+	bytes[5] = byte((p3&0x000F0000)>>12) | byte((p2&0x000F0000)>>16)
+	bytes[6] = byte((p2 & 0x0000FF00) >> 8)
+	bytes[7] = byte(p2 & 0x000000FF)
 	if d.By4 {
 		bytes[2] |= 0xC
 	}
-	return bytes
+}
+
+// Decode sets A, B, and C from the three parameters that represent the ratio in the Si5351's registers.
+// Decode is the inverse of Encode.
+func (d *FractionalRatio) Decode(p1, p2, p3 uint32) error {
+	if p3 == 0 {
+		if p1 != 0 || p2 != 0 {
+			return fmt.Errorf("%w %d, %d, %d: p3 must not be 0", ErrInvalidRatio, p1, p2, p3)
+		}
+		d.A, d.B, d.C = 0, 0, 0
+		return nil
+	}
+
+	// a + b/c = (p1 + 512 + p2/p3) / 128
+	numerator := uint64(p1+512)*uint64(p3) + uint64(p2)
+	denominator := 128 * uint64(p3)
+	a := numerator / denominator
+	b := numerator % denominator
+	c := denominator
+	divisor := gcd(b, c)
+	b /= divisor
+	c /= divisor
+	if uint64(p3)%c == 0 {
+		// keep the denominator of the register representation
+		b *= uint64(p3) / c
+		c = uint64(p3)
+	}
+	if c > maxDenominator {
+		return fmt.Errorf("%w %d, %d, %d: denominator %d too large", ErrInvalidRatio, p1, p2, p3, c)
+	}
+
+	d.A, d.B, d.C = uint32(a), uint32(b), uint32(c)
+	return nil
+}
+
+// DecodeParameters extracts the three parameters that represent a ratio from the given register bytes.
+// DecodeParameters is the inverse of the encoding done by Bytes.
+func DecodeParameters(bytes []byte) (p1, p2, p3 uint32) {
+	p1 = uint32(bytes[2]&0x03)<<16 | uint32(bytes[3])<<8 | uint32(bytes[4])
+	p2 = uint32(bytes[5]&0x0F)<<16 | uint32(bytes[6])<<8 | uint32(bytes[7])
+	p3 = uint32(bytes[5]&0xF0)<<12 | uint32(bytes[0])<<8 | uint32(bytes[1])
+	return
+}
+
+// ParseFractionalRatio decodes the representation of a ratio in the Si5351's registers as produced by Bytes,
+// including the R divider and the DIVBY4 bits.
+func ParseFractionalRatio(bytes []byte) (FractionalRatio, error) {
+	if len(bytes) < 8 {
+		return FractionalRatio{}, fmt.Errorf("a ratio needs 8 bytes, got %d", len(bytes))
+	}
+
+	result := FractionalRatio{
+		ClockDivider: ClockDivider((bytes[2] >> 4) & 7),
+		By4:          bytes[2]&0x0C == 0x0C,
+	}
+	err := result.Decode(DecodeParameters(bytes))
+	return result, err
+}
+
+// parseLoadedRatio decodes a ratio that was read from the Si5351. Invalid parameters, e.g. in the uninitialized registers
+// of an unused PLL or Multisynth after power-up, result in the zero ratio instead of an error.
+func parseLoadedRatio(bytes []byte) (FractionalRatio, error) {
+	result, err := ParseFractionalRatio(bytes)
+	if errors.Is(err, ErrInvalidRatio) {
+		return FractionalRatio{}, nil
+	}
+	return result, err
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
 }
 
 // WriteTo writes the register representation to the given writer.
@@ -104,55 +237,65 @@ func (d *FractionalRatio) WriteTo(w io.Writer) (int64, error) {
 	return int64(n), err
 }
 
-// FindFractionalMultiplier calculates a fractional ratio that allows to generate the given frequency from the given reference frequency.
-func FindFractionalMultiplier(refFrequency, frequency Frequency) FractionalRatio {
-	const (
-		minA, maxA   = 15, 90
-		defaultDenom = 0xFFFFF // 1048575 // (1 << 21) - 1 // 2000000
-	)
+// FindFractionalMultiplier calculates the fractional ratio that is closest to the ratio between the given frequency
+// and the given reference frequency, with a denominator of at most 1048575.
+// If the ratio is out of the range of the PLL multiplier (15-90), FindFractionalMultiplier returns a *RangeError that
+// wraps ErrPLLOutOfRange.
+func FindFractionalMultiplier(refFrequency, frequency Frequency) (FractionalRatio, error) {
+	const minA, maxA = MinPLLMultiplier, MaxPLLMultiplier
 
-	q := float64(frequency / refFrequency)
-	a := uint32(q)
-	if a < minA {
-		a = minA
-	} else if a > maxA {
-		a = maxA
+	if refFrequency <= 0 {
+		return FractionalRatio{}, fmt.Errorf("invalid reference frequency %.0fHz", refFrequency)
+	}
+	q := new(big.Rat).Quo(frequency.Rat(), refFrequency.Rat())
+	if q.Cmp(big.NewRat(minA, 1)) < 0 || q.Cmp(big.NewRat(maxA, 1)) > 0 {
+		return FractionalRatio{}, &RangeError{What: "PLL multiplier", Frequency: frequency, Min: minA * refFrequency, Max: maxA * refFrequency, Err: ErrPLLOutOfRange}
 	}
-	b := uint32((q - float64(a)) * float64(defaultDenom))
-	c := uint32(defaultDenom)
 
-	return FractionalRatio{A: a, B: b, C: c}
+	return ApproximateRatio(q, maxDenominator), nil
 }
 
-// FindFractionalDivider calculates a fractional ration that allows to generate the given frequency from the given reference frequency.
-func FindFractionalDivider(refFrequency Frequency, frequency Frequency) FractionalRatio {
-	const (
-		minA, maxA   = 6, 1800
-		defaultDenom = 0xFFFFF // 1048575 // (1<<21) - 1 // 2000000
-	)
+// FindFractionalDivider calculates the fractional ratio that is closest to the ratio between the given reference frequency
+// and the given frequency, with a denominator of at most 1048575.
+// If the ratio exceeds the range of the Multisynth divider, the R divider is used additionally to divide the frequency by up to 128.
+// If the ratio is still out of range, FindFractionalDivider returns a *RangeError that wraps ErrOutputOutOfRange.
+func FindFractionalDivider(refFrequency Frequency, frequency Frequency) (FractionalRatio, error) {
+	const minA, maxA = MinMultisynthDivider, MaxMultisynthDivider
 
-	q := float64(refFrequency / frequency)
-	a := uint32(q)
-	if a < minA {
-		a = minA
-	} else if a > maxA {
-		a = maxA
+	if frequency <= 0 {
+		return FractionalRatio{}, &RangeError{What: "outputs", Frequency: frequency, Min: MinOutputFrequency, Max: MaxOutputFrequency, Err: ErrOutputOutOfRange}
+	}
+	q := new(big.Rat).Quo(refFrequency.Rat(), frequency.Rat())
+	clockDivider := ClockBy1
+	for q.Cmp(big.NewRat(maxA, 1)) > 0 && clockDivider < ClockBy128 {
+		q.Quo(q, big.NewRat(2, 1))
+		clockDivider++
+	}
+	if q.Cmp(big.NewRat(minA, 1)) < 0 || q.Cmp(big.NewRat(maxA, 1)) > 0 {
+		return FractionalRatio{}, &RangeError{What: "Multisynth divider", Frequency: frequency, Min: refFrequency / (maxA * Frequency(ClockBy128.Factor())), Max: refFrequency / minA, Err: ErrOutputOutOfRange}
 	}
-	b := uint32((q - float64(a)) * float64(defaultDenom))
-	c := uint32(defaultDenom)
 
-	return FractionalRatio{A: a, B: b, C: c}
+	result := ApproximateRatio(q, maxDenominator)
+	result.ClockDivider = clockDivider
+
+	return result, nil
 }
 
 // FindFractionalMultiplierWithIntegerDivider calculates a pair of ratios, where the divider is integer.
-func FindFractionalMultiplierWithIntegerDivider(refFrequency Frequency, frequency Frequency) (multiplier, divider FractionalRatio) {
-	const (
-		minPLLFreq, maxPLLFreq = 600 * MHz, 900 * MHz
-		maxFreq                = 150 * MHz
-		minA, maxA             = 6, 126
-	)
-
-	pllFrequency := minPLLFreq
+// Frequencies above 150MHz are generated using the divide-by-4 mode of the Multisynth.
+// If the frequency cannot be generated with a PLL frequency of 600-900MHz, FindFractionalMultiplierWithIntegerDivider
+// returns a *RangeError.
+func FindFractionalMultiplierWithIntegerDivider(refFrequency Frequency, frequency Frequency) (multiplier, divider FractionalRatio, err error) {
+	const minA, maxA = 6, 126
+
+	if err := checkOutputFrequency(frequency); err != nil {
+		return FractionalRatio{}, FractionalRatio{}, err
+	}
+	if frequency > MinBy4Frequency {
+		return FindFractionalMultiplierWithBy4Divider(refFrequency, frequency)
+	}
+
+	pllFrequency := MinPLLFrequency
 	a := uint32(pllFrequency / frequency)
 
 	for pllFrequency/frequency != Frequency(uint32(pllFrequency/frequency)) {
@@ -164,18 +307,79 @@ func FindFractionalMultiplierWithIntegerDivider(refFrequency Frequency, frequenc
 	}
 
 	clockDivider := ClockBy1
-	for a > maxA {
-		a = a >> 1
+	for a > maxA && clockDivider < ClockBy128 {
+		// round up to keep the PLL frequency above its minimum
+		a = (a + 1) >> 1
 		clockDivider++
 	}
+	if a%2 == 1 {
+		a++
+	}
 
 	pllFrequency = frequency * Frequency(a*uint32(clockDivider.Factor()))
-	multiplier = FindFractionalMultiplier(refFrequency, pllFrequency)
+	if a > maxA {
+		return FractionalRatio{}, FractionalRatio{}, &RangeError{What: "integer divider", Frequency: frequency, Min: MinPLLFrequency / (maxA * Frequency(ClockBy128.Factor())), Max: MaxOutputFrequency, Err: ErrOutputOutOfRange}
+	}
+	if err := checkPLLFrequency(pllFrequency); err != nil {
+		return FractionalRatio{}, FractionalRatio{}, err
+	}
+	multiplier, err = FindFractionalMultiplier(refFrequency, pllFrequency)
+	if err != nil {
+		return FractionalRatio{}, FractionalRatio{}, err
+	}
 
 	divider = FractionalRatio{A: uint32(a), B: 0, C: 1, ClockDivider: clockDivider}
-	if clockDivider == ClockBy4 {
-		divider.By4 = true
+
+	return multiplier, divider, nil
+}
+
+// MinBy4Frequency is the output frequency above which the divide-by-4 mode of the Multisynth must be used.
+const MinBy4Frequency = 150 * MHz
+
+// FindFractionalMultiplierWithBy4Divider calculates a pair of ratios for output frequencies between 150MHz and 200MHz,
+// where the divider uses the divide-by-4 mode of the Multisynth. The PLL runs at four times the output frequency.
+func FindFractionalMultiplierWithBy4Divider(refFrequency Frequency, frequency Frequency) (multiplier, divider FractionalRatio, err error) {
+	if frequency <= MinBy4Frequency || frequency > MaxOutputFrequency {
+		return FractionalRatio{}, FractionalRatio{}, &RangeError{What: "divide-by-4 mode", Frequency: frequency, Min: MinBy4Frequency, Max: MaxOutputFrequency, Err: ErrOutputOutOfRange}
+	}
+	multiplier, err = FindFractionalMultiplier(refFrequency, 4*frequency)
+	if err != nil {
+		return FractionalRatio{}, FractionalRatio{}, err
 	}
+	divider = FractionalRatio{A: 4, B: 0, C: 1, By4: true}
+	return multiplier, divider, nil
+}
 
-	return
+// FindIntegerDivider calculates an even integer divider and an R divider that allow to generate the closest possible value
+// of the given frequency from the given reference frequency with the integer outputs CLK6 and CLK7.
+// If the frequency is out of the range of the dividers, FindIntegerDivider returns a *RangeError that wraps ErrOutputOutOfRange.
+func FindIntegerDivider(refFrequency Frequency, frequency Frequency) (divider uint8, rDiv ClockDivider, err error) {
+	minFrequency := refFrequency / (MaxIntegerDivider * Frequency(ClockBy128.Factor()))
+	maxFrequency := refFrequency / MinIntegerDivider
+	if frequency < minFrequency || frequency > maxFrequency {
+		return 0, 0, &RangeError{What: "integer divider", Frequency: frequency, Min: minFrequency, Max: maxFrequency, Err: ErrOutputOutOfRange}
+	}
+
+	bestError := Frequency(-1)
+	for r := ClockBy1; r <= ClockBy128; r++ {
+		q := float64(refFrequency / (frequency * Frequency(r.Factor())))
+		d := 2 * uint32(q/2+0.5)
+		if d < MinIntegerDivider {
+			d = MinIntegerDivider
+		} else if d > MaxIntegerDivider {
+			d = MaxIntegerDivider
+		}
+
+		actual := refFrequency / (Frequency(d) * Frequency(r.Factor()))
+		e := actual - frequency
+		if e < 0 {
+			e = -e
+		}
+		if bestError < 0 || e < bestError {
+			bestError = e
+			divider = uint8(d)
+			rDiv = r
+		}
+	}
+	return divider, rDiv, nil
 }
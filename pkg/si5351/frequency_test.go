@@ -1,6 +1,7 @@
 package si5351
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"testing"
@@ -30,7 +31,8 @@ func TestFindFractionalMultiplier(t *testing.T) {
 		frequency := Frequency(f) * MHz
 		t.Run(fmt.Sprintf("%f", frequency), func(t *testing.T) {
 			t.Parallel()
-			multiplier := FindFractionalMultiplier(crystal.Frequency(), frequency)
+			multiplier, err := FindFractionalMultiplier(crystal.Frequency(), frequency)
+			assert.NoError(t, err)
 			actual := multiplier.Multiply(crystal.Frequency())
 			assert.True(t, math.Abs(float64(frequency-actual)) < 2, "", actual, multiplier, crystal.Frequency())
 		})
@@ -43,7 +45,8 @@ func TestFindFractionalDivider(t *testing.T) {
 		frequency := Frequency(f) * KHz
 		t.Run(fmt.Sprintf("%f", frequency), func(t *testing.T) {
 			t.Parallel()
-			divider := FindFractionalDivider(pllFrequency, frequency)
+			divider, err := FindFractionalDivider(pllFrequency, frequency)
+			assert.NoError(t, err)
 			actual := divider.Divide(pllFrequency)
 			assert.True(t, math.Abs(float64(frequency-actual)) < 1, "", actual)
 		})
@@ -56,9 +59,148 @@ func TestFindFractionalMultiplierWithIntegerDivider(t *testing.T) {
 		frequency := Frequency(f) * KHz
 		t.Run(fmt.Sprintf("%f", frequency), func(t *testing.T) {
 			t.Parallel()
-			multiplier, divider := FindFractionalMultiplierWithIntegerDivider(refFrequency, frequency)
+			multiplier, divider, err := FindFractionalMultiplierWithIntegerDivider(refFrequency, frequency)
+			assert.NoError(t, err)
+			assert.True(t, divider.IsInteger(), "", divider)
 			actual := divider.Divide(multiplier.Multiply(refFrequency))
 			assert.True(t, math.Abs(float64(frequency-actual)) < 4, "", actual, multiplier, divider)
 		})
 	}
 }
+
+func TestParseFractionalRatio(t *testing.T) {
+	testCases := []FractionalRatio{
+		{A: 36, B: 0, C: 1},
+		{A: 36, B: 3, C: 7},
+		{A: 35, B: 1048574, C: 1048575},
+		{A: 127, B: 226, C: 1000},
+		{A: 1800, B: 0, C: 1, ClockDivider: ClockBy128},
+		{A: 15, B: 52428, C: 65536, ClockDivider: ClockBy2},
+		{A: 4, B: 0, C: 1, By4: true},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%v", tc), func(t *testing.T) {
+			bytes := tc.Bytes()
+
+			actual, err := ParseFractionalRatio(bytes)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc, actual)
+			assert.Equal(t, bytes, actual.Bytes())
+		})
+	}
+}
+
+func TestParseFractionalRatioCanonicalizesUnsetDenominator(t *testing.T) {
+	ratio := FractionalRatio{A: 36}
+	bytes := ratio.Bytes()
+
+	actual, err := ParseFractionalRatio(bytes)
+
+	assert.NoError(t, err)
+	assert.Equal(t, FractionalRatio{A: 36, C: 1}, actual)
+	assert.Equal(t, bytes, actual.Bytes())
+}
+
+func TestDecodeParameters(t *testing.T) {
+	ratio := FractionalRatio{A: 35, B: 1048574, C: 1048575}
+	p1, p2, p3 := ratio.Encode()
+
+	actualP1, actualP2, actualP3 := DecodeParameters(ratio.Bytes())
+
+	assert.Equal(t, p1, actualP1)
+	assert.Equal(t, p2, actualP2)
+	assert.Equal(t, p3, actualP3)
+}
+
+func TestParseFractionalRatioInvalid(t *testing.T) {
+	_, err := ParseFractionalRatio([]byte{0, 0, 0})
+	assert.Error(t, err)
+
+	_, err = ParseFractionalRatio([]byte{0, 0, 0, 0, 1, 0, 0, 0})
+	assert.Error(t, err)
+
+	actual, err := ParseFractionalRatio(make([]byte, 8))
+	assert.NoError(t, err)
+	assert.Equal(t, FractionalRatio{}, actual)
+}
+
+func TestFindIntegerDivider(t *testing.T) {
+	pllFrequency := 900 * MHz
+	for f := 30; f <= 9000; f += 30 {
+		frequency := Frequency(f) * KHz
+		t.Run(fmt.Sprintf("%f", frequency), func(t *testing.T) {
+			divider, rDiv, err := FindIntegerDivider(pllFrequency, frequency)
+			assert.NoError(t, err)
+			assert.Equal(t, uint8(0), divider%2)
+			assert.True(t, divider >= MinIntegerDivider && divider <= MaxIntegerDivider)
+			actual := pllFrequency / (Frequency(divider) * Frequency(rDiv.Factor()))
+			assert.True(t, math.Abs(float64(frequency-actual))/float64(frequency) < 0.01, "", actual, divider, rDiv)
+		})
+	}
+}
+
+func TestFindFractionalDividerWithClockDivider(t *testing.T) {
+	pllFrequency := 900 * MHz
+	for f := 8000; f <= 500000; f += 500 {
+		frequency := Frequency(f) * Hz
+		t.Run(fmt.Sprintf("%f", frequency), func(t *testing.T) {
+			t.Parallel()
+			divider, err := FindFractionalDivider(pllFrequency, frequency)
+			assert.NoError(t, err)
+			actual := divider.Divide(pllFrequency)
+			assert.True(t, divider.A <= 1800, "", divider)
+			assert.True(t, math.Abs(float64(frequency-actual)) < 0.01, "", actual, divider)
+		})
+	}
+}
+
+func TestFindFractionalMultiplierWithIntegerDividerDoesNotMixUpClockDivider(t *testing.T) {
+	_, divider, err := FindFractionalMultiplierWithIntegerDivider(25*MHz, 1500*KHz)
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, ClockBy4, divider.ClockDivider)
+	assert.False(t, divider.By4)
+}
+
+func TestFindFractionalMultiplierWithBy4Divider(t *testing.T) {
+	refFrequency := 25 * MHz
+	for f := 150100; f <= 200000; f += 100 {
+		frequency := Frequency(f) * KHz
+		t.Run(fmt.Sprintf("%f", frequency), func(t *testing.T) {
+			t.Parallel()
+			multiplier, divider, err := FindFractionalMultiplierWithIntegerDivider(refFrequency, frequency)
+			assert.NoError(t, err)
+			pllFrequency := multiplier.Multiply(refFrequency)
+			actual := divider.Divide(pllFrequency)
+			assert.True(t, divider.By4)
+			assert.Equal(t, []byte{0, 1, 0x0C, 0, 0, 0, 0, 0}, divider.Bytes())
+			assert.True(t, pllFrequency >= 600*MHz && pllFrequency <= 800*MHz, "", pllFrequency)
+			assert.True(t, math.Abs(float64(frequency-actual)) < 0.001, "", actual, multiplier, divider)
+		})
+	}
+}
+
+func TestFindRatiosOutOfRange(t *testing.T) {
+	_, err := FindFractionalMultiplier(25*MHz, 300*MHz)
+	assert.True(t, errors.Is(err, ErrPLLOutOfRange), "%v", err)
+	_, err = FindFractionalMultiplier(25*MHz, 2300*MHz)
+	assert.True(t, errors.Is(err, ErrPLLOutOfRange), "%v", err)
+
+	_, err = FindFractionalDivider(900*MHz, 160*MHz)
+	assert.True(t, errors.Is(err, ErrOutputOutOfRange), "%v", err)
+	_, err = FindFractionalDivider(900*MHz, 3*KHz)
+	assert.True(t, errors.Is(err, ErrOutputOutOfRange), "%v", err)
+
+	_, _, err = FindFractionalMultiplierWithIntegerDivider(25*MHz, 10*KHz)
+	assert.True(t, errors.Is(err, ErrOutputOutOfRange), "%v", err)
+	_, _, err = FindFractionalMultiplierWithBy4Divider(25*MHz, 210*MHz)
+	assert.True(t, errors.Is(err, ErrOutputOutOfRange), "%v", err)
+
+	_, _, err = FindIntegerDivider(900*MHz, 20*KHz)
+	assert.True(t, errors.Is(err, ErrOutputOutOfRange), "%v", err)
+	var rangeError *RangeError
+	assert.True(t, errors.As(err, &rangeError))
+	assert.Equal(t, 20*KHz, rangeError.Frequency)
+}
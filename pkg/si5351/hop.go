@@ -0,0 +1,131 @@
+package si5351
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrStaleHopTable indicates that the divider of an output was changed by other means than its HopTable.
+var ErrStaleHopTable = errors.New("the divider of the output was changed outside of the hop table")
+
+// HopTable contains precompiled register bursts that switch an output between a list of frequencies.
+// Each hop is a single write of the bytes of the Multisynth parameters that differ between the frequencies of the table,
+// the PLL is not touched. Hop itself does not allocate any memory, but the bus might: I2CBus writes without allocations,
+// the WriteReg method of *i2c.I2C allocates a new buffer for each write.
+type HopTable struct {
+	Output OutputIndex
+	// Tunings contains the exact effective frequency of each hop.
+	Tunings []Tuning
+
+	output   *FractionalOutput
+	bus      Bus
+	register uint8
+	bursts   [][]byte
+	dividers []FractionalRatio
+	last     FractionalRatio
+}
+
+// CompileHops precompiles the register bursts to switch the given output between the given frequencies.
+// The output must be one of CLK0-CLK5, its PLL keeps its current frequency, see FixedPLLStrategy. The dividers use the fixed
+// TuningDenominator, only the bytes that differ between the frequencies and the current divider of the output are part
+// of the bursts. Frequencies that need another PLL frequency, like frequencies above 150MHz, are rejected.
+// If the output runs in integer mode, CompileHops switches it to fractional mode.
+// The table is only valid as long as the divider of the output is not changed by other means.
+func (s *Si5351) CompileHops(output OutputIndex, frequencies ...Frequency) (*HopTable, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Variant.checkOutput(output); err != nil {
+		return nil, err
+	}
+	if output >= Clk6 {
+		return nil, fmt.Errorf("only CLK0-CLK5 support hop tables: %w", ErrUnsupportedOutput)
+	}
+	if len(frequencies) == 0 {
+		return nil, errors.New("no frequencies to hop")
+	}
+
+	o := s.fractionalOutput[output]
+	p := s.pll[o.PLL]
+	refFrequency := s.referenceFrequency(o.PLL)
+	if p.Multiplier.Rat().Sign() == 0 {
+		return nil, fmt.Errorf("PLL%v of CLK%d is not set up", o.PLL, output)
+	}
+	current := TuningRatios{Multiplier: p.Multiplier, Divider: o.FrequencyDivider}
+	pllFrequency := s.exactPLLFrequency(o.PLL)
+
+	result := &HopTable{
+		Output:   output,
+		Tunings:  make([]Tuning, len(frequencies)),
+		output:   o,
+		bus:      s.bus,
+		dividers: make([]FractionalRatio, len(frequencies)),
+		last:     o.FrequencyDivider,
+	}
+	encoded := make([][]byte, len(frequencies))
+	currentBytes := o.FrequencyDivider.Bytes()
+	first, last := len(currentBytes), -1
+	for i, frequency := range frequencies {
+		ratios, err := FixedPLLStrategy{}.Ratios(refFrequency, current, frequency)
+		if err != nil {
+			return nil, err
+		}
+		if ratios.Multiplier != p.Multiplier {
+			return nil, fmt.Errorf("%.0fHz cannot be generated without changing the frequency of PLL%v", frequency, o.PLL)
+		}
+		result.dividers[i] = ratios.Divider
+		result.Tunings[i] = Tuning{Requested: frequency, Achieved: ratios.Divider.DivideExact(pllFrequency)}
+		encoded[i] = ratios.Divider.Bytes()
+
+		// a byte that differs between two dividers differs from the current divider for at least one of them
+		f, l, changed := changedRange(currentBytes, encoded[i])
+		if changed && f < first {
+			first = f
+		}
+		if changed && l > last {
+			last = l
+		}
+	}
+	if last == -1 {
+		// all frequencies use the current divider, write the last byte to keep each hop a single write
+		first, last = len(currentBytes)-1, len(currentBytes)-1
+	}
+
+	result.register = o.Register.Divider + uint8(first)
+	result.bursts = make([][]byte, len(frequencies))
+	for i, bytes := range encoded {
+		result.bursts[i] = bytes[first : last+1]
+	}
+
+	if o.IntegerMode {
+		if err := o.setIntegerMode(false); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Len returns the number of frequencies in this table.
+func (h *HopTable) Len() int {
+	return len(h.bursts)
+}
+
+// Hop switches the output to the frequency with the given index with a single write on the bus.
+func (h *HopTable) Hop(index int) error {
+	h.output.mutex.Lock()
+	defer h.output.mutex.Unlock()
+
+	if index < 0 || index >= len(h.bursts) {
+		return fmt.Errorf("invalid hop %d, must be within 0-%d", index, len(h.bursts)-1)
+	}
+	if h.output.FrequencyDivider != h.last {
+		return ErrStaleHopTable
+	}
+
+	if err := writeRegisters(h.bus, h.register, h.bursts[index]...); err != nil {
+		return err
+	}
+	h.last = h.dividers[index]
+	h.output.FrequencyDivider = h.last
+	return nil
+}
@@ -0,0 +1,111 @@
+package si5351
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupHopDevice(bus Bus) *Si5351 {
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.SetupPLL(PLLA, 900*MHz)
+	device.PrepareOutputs(PLLA, false, ClockInputMultisynth, OutputDrive2mA, OutputDisableLow, Clk0)
+	device.SetOutputFrequency(Clk0, 10*MHz)
+	return device
+}
+
+func TestCompileHops(t *testing.T) {
+	bus := new(fakeBus)
+	device := setupHopDevice(bus)
+	assert.True(t, device.Clk0().IntegerMode)
+	frequencies := []Frequency{14074000, 14074006.25, 14074012.5, 14074018.75}
+	device.TuneOutput(Clk0, frequencies[0]) // keeps the bytes of P3 out of the bursts
+
+	hops, err := device.CompileHops(Clk0, frequencies...)
+	assert.NoError(t, err)
+	assert.Equal(t, len(frequencies), hops.Len())
+	assert.False(t, device.Clk0().IntegerMode)
+
+	writes := recordWrites(bus)
+	for _, i := range []int{2, 0, 3, 1, 1} {
+		*writes = nil
+		err := hops.Hop(i)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(*writes))
+		assert.True(t, len((*writes)[0].values) < 8)
+		actual, err := ParseFractionalRatio(bus.registers[RegMultisynth0Parameters : RegMultisynth0Parameters+8])
+		assert.NoError(t, err)
+		assert.Equal(t, device.Clk0().FrequencyDivider, actual)
+		assert.Equal(t, actual.DivideExact(device.exactPLLFrequency(PLLA)), hops.Tunings[i].Achieved)
+		deviation, _ := hops.Tunings[i].Deviation().Float64()
+		assert.InDelta(t, 0, deviation, 0.15)
+	}
+
+	assert.Error(t, hops.Hop(4))
+	device.SetOutputFrequency(Clk0, 10*MHz)
+	assert.Equal(t, ErrStaleHopTable, hops.Hop(0))
+}
+
+func TestCompileHopsInvalid(t *testing.T) {
+	device := setupHopDevice(new(fakeBus))
+
+	_, err := device.CompileHops(Clk0, 7*MHz, 180*MHz)
+	assert.Error(t, err)
+	_, err = device.CompileHops(Clk6, 7*MHz)
+	assert.Error(t, err)
+	_, err = device.CompileHops(Clk0)
+	assert.Error(t, err)
+}
+
+func TestHopDoesNotAllocate(t *testing.T) {
+	for name, bus := range hopBuses() {
+		device := setupHopDevice(bus)
+		device.TuneOutput(Clk0, 7074000)
+		hops, _ := device.CompileHops(Clk0, 7074000, 7074006.25, 7074012.5, 7074018.75)
+
+		i := 0
+		allocs := testing.AllocsPerRun(1000, func() {
+			hops.Hop(i % hops.Len())
+			i++
+		})
+
+		assert.Equal(t, 0.0, allocs, name)
+	}
+}
+
+func BenchmarkHop(b *testing.B) {
+	for name, bus := range hopBuses() {
+		b.Run(name, func(b *testing.B) {
+			device := setupHopDevice(bus)
+			device.TuneOutput(Clk0, 7074000)
+			hops, _ := device.CompileHops(Clk0, 7074000, 7074006.25, 7074012.5, 7074018.75)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				hops.Hop(i % hops.Len())
+			}
+		})
+	}
+}
+
+// hopBuses returns the buses on which to measure the hops: the fake bus and an I2CBus on a device
+// that behaves like the Linux I2C device driver.
+func hopBuses() map[string]Bus {
+	return map[string]Bus{
+		"fakeBus": new(fakeBus),
+		"I2CBus":  &I2CBus{device: &fakeI2CDevice{}},
+	}
+}
+
+func BenchmarkSetOutputFrequency(b *testing.B) {
+	device := setupHopDevice(new(fakeBus))
+	frequencies := []Frequency{7074000, 7074006.25, 7074012.5, 7074018.75}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		device.SetOutputFrequency(Clk0, frequencies[i%len(frequencies)])
+	}
+}
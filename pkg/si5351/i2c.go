@@ -0,0 +1,110 @@
+package si5351
+
+import (
+	"io"
+
+	"github.com/ftl/i2c"
+)
+
+// I2CBus connects to the Si5351 through the Linux I2C device driver, using github.com/ftl/i2c.
+// The ReadReg method of *i2c.I2C reads the first register again and again and drops the values it has read,
+// I2CBus reads the registers in one transfer instead: the Si5351 increments the register address with each byte.
+// The WriteReg method of *i2c.I2C allocates a new buffer for each write, I2CBus reuses its buffer.
+// *i2c.I2C keeps the first error and fails all further accesses, I2CBus reopens the device after an error instead,
+// so an error only fails the access in which it happened.
+// I2CBus is not safe for concurrent use, the Si5351 serializes its access to the bus.
+type I2CBus struct {
+	device io.ReadWriteCloser
+	open   func() (io.ReadWriteCloser, error)
+	buffer []byte
+	err    error
+}
+
+// OpenI2C opens the connection to the Si5351 with the given address on the I2C bus with the given number.
+func OpenI2C(address uint8, bus int) (*I2CBus, error) {
+	open := func() (io.ReadWriteCloser, error) {
+		return i2c.Open(address, bus)
+	}
+	device, err := open()
+	if err != nil {
+		return nil, err
+	}
+	return &I2CBus{device: device, open: open}, nil
+}
+
+// ReadReg reads len(p) bytes, starting at the given register.
+func (b *I2CBus) ReadReg(reg uint8, p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := readI2C(b.device, reg, p)
+	if err != nil {
+		b.reopen()
+	}
+	return n, err
+}
+
+// WriteReg writes the given values, starting at the given register.
+func (b *I2CBus) WriteReg(reg uint8, values ...byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	b.buffer = append(b.buffer[:0], reg)
+	b.buffer = append(b.buffer, values...)
+	n, err := b.device.Write(b.buffer)
+	if err != nil {
+		b.reopen()
+	}
+	return n, err
+}
+
+// RegWriter returns a writer that writes to the given register.
+func (b *I2CBus) RegWriter(reg uint8) io.Writer {
+	return &regWriter{reg: reg, bus: b}
+}
+
+// Err returns the error that happened when the device was reopened after a failed access. All further reads
+// and writes fail with this error. The errors of the accesses themselves are only returned by ReadReg and WriteReg.
+func (b *I2CBus) Err() error {
+	return b.err
+}
+
+// reopen closes the device and opens it again to clear the error of the device.
+func (b *I2CBus) reopen() {
+	if b.open == nil {
+		return
+	}
+	b.device.Close()
+	device, err := b.open()
+	if err != nil {
+		b.device = nil
+		b.err = err
+		return
+	}
+	b.device = device
+}
+
+// Close the connection.
+func (b *I2CBus) Close() error {
+	if b.device == nil {
+		return b.err
+	}
+	return b.device.Close()
+}
+
+// readI2C writes the address of the given register and reads len(p) bytes in one transfer.
+func readI2C(device io.ReadWriter, reg uint8, p []byte) (int, error) {
+	if _, err := device.Write([]byte{reg}); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(device, p)
+}
+
+type regWriter struct {
+	reg uint8
+	bus Bus
+}
+
+func (w *regWriter) Write(p []byte) (int, error) {
+	return w.bus.WriteReg(w.reg, p...)
+}
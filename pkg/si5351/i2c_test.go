@@ -0,0 +1,131 @@
+package si5351
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeI2CDevice behaves like a Si5351 behind the Linux I2C device driver: a write sets the register address and writes
+// the following bytes, a read returns the registers starting at the register address. The register address is
+// incremented with each byte. Like github.com/ftl/i2c, the device keeps its error and fails all further accesses.
+type fakeI2CDevice struct {
+	registers [256]byte
+	address   uint8
+	err       error
+	closed    bool
+}
+
+func (d *fakeI2CDevice) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	for i := range p {
+		p[i] = d.registers[d.address]
+		d.address++
+	}
+	return len(p), nil
+}
+
+func (d *fakeI2CDevice) Write(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	d.address = p[0]
+	for _, value := range p[1:] {
+		d.registers[d.address] = value
+		d.address++
+	}
+	return len(p), nil
+}
+
+func (d *fakeI2CDevice) Close() error {
+	d.closed = true
+	return nil
+}
+
+func TestLoadThroughI2CBus(t *testing.T) {
+	device := &fakeI2CDevice{}
+	bus := &I2CBus{device: device}
+	configured := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	configured.StartSetup()
+	configured.SetupPLL(PLLA, 800*MHz)
+	configured.PrepareOutputs(PLLA, false, ClockInputMultisynth, OutputDrive8mA, OutputDisableHighZ, Clk1)
+	configured.SetOutputFrequency(Clk1, 7100*KHz)
+	configured.FinishSetup()
+
+	loaded := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	err := loaded.Load()
+
+	assert.NoError(t, err)
+	assert.Equal(t, configured.PLLA().Multiplier, loaded.PLLA().Multiplier)
+	assert.Equal(t, configured.Clk1().FrequencyDivider, loaded.Clk1().FrequencyDivider)
+	assert.Equal(t, OutputDrive8mA, loaded.Clk1().Drive)
+	assert.Equal(t, OutputDisableHighZ, loaded.Clk1().DisableState)
+	assert.True(t, loaded.Clk1().Enabled)
+	assert.NoError(t, bus.Err())
+}
+
+func TestI2CBusReopensAfterError(t *testing.T) {
+	failing := &fakeI2CDevice{err: errors.New("remote I/O error")}
+	chip := &fakeI2CDevice{}
+	bus := &I2CBus{device: failing, open: func() (io.ReadWriteCloser, error) {
+		return chip, nil
+	}}
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+
+	_, err := device.SetupPLL(PLLA, 800*MHz)
+	assert.True(t, errors.Is(err, failing.err))
+	assert.True(t, failing.closed)
+	assert.NoError(t, bus.Err())
+
+	_, err = device.SetupPLL(PLLA, 800*MHz)
+	assert.NoError(t, err)
+	actual, err := ParseFractionalRatio(chip.registers[RegPLLAMultisynthParameters : RegPLLAMultisynthParameters+8])
+	assert.NoError(t, err)
+	assert.Equal(t, device.PLLA().Multiplier, actual)
+}
+
+func TestI2CBusCannotReopen(t *testing.T) {
+	openErr := errors.New("no such device")
+	bus := &I2CBus{device: &fakeI2CDevice{err: errors.New("remote I/O error")}, open: func() (io.ReadWriteCloser, error) {
+		return nil, openErr
+	}}
+
+	_, err := bus.WriteReg(RegPLLReset, 0xA0)
+	assert.Error(t, err)
+	assert.Equal(t, openErr, bus.Err())
+	_, err = bus.WriteReg(RegPLLReset, 0xA0)
+	assert.Equal(t, openErr, err)
+	assert.Equal(t, openErr, bus.Close())
+}
+
+func TestLoadWithInvalidRatio(t *testing.T) {
+	device := &fakeI2CDevice{}
+	bus := &I2CBus{device: device}
+	configured := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	configured.SetupPLL(PLLA, 800*MHz)
+	configured.PrepareOutputs(PLLA, false, ClockInputMultisynth, OutputDrive8mA, OutputDisableHighZ, Clk1)
+	configured.SetOutputFrequency(Clk1, 7100*KHz)
+	// P3 = 0 with P1 != 0 and P2 != 0, like the garbage in unused blocks after power-up
+	copy(device.registers[RegPLLBMultisynthParameters:], []byte{0x00, 0x00, 0x00, 0x0A, 0x50, 0x03, 0x12, 0x34})
+	copy(device.registers[RegMultisynth2Parameters:], []byte{0x00, 0x00, 0x01, 0xFF, 0xFF, 0x0F, 0xFF, 0xFF})
+
+	loaded := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	err := loaded.Load()
+
+	assert.NoError(t, err)
+	assert.True(t, loaded.PLLB().Multiplier.IsZero())
+	assert.True(t, loaded.Clk2().FrequencyDivider.IsZero())
+	assert.False(t, loaded.PLLA().Multiplier.IsZero())
+	assert.Equal(t, configured.PLLA().Multiplier, loaded.PLLA().Multiplier)
+	assert.Equal(t, configured.Clk1().FrequencyDivider, loaded.Clk1().FrequencyDivider)
+
+	_, err = ParseFractionalRatio(device.registers[RegPLLBMultisynthParameters : RegPLLBMultisynthParameters+8])
+	assert.True(t, errors.Is(err, ErrInvalidRatio), "%v", err)
+}
@@ -1,5 +1,11 @@
 package si5351
 
+import (
+	"fmt"
+	"math/big"
+	"sync"
+)
+
 // OutputIndex indicates one of the output clocks.
 type OutputIndex int
 
@@ -16,16 +22,23 @@ const (
 )
 
 // Output describes the properties common to all of the Si5351's output clocks.
+// IntegerMode has no effect on CLK6 and CLK7, bit 6 of their control registers selects the integer mode of PLL A and PLL B.
 type Output struct {
-	Register    OutputRegister
-	PowerDown   bool
-	IntegerMode bool
-	Invert      bool
-	PLL         PLLIndex
-	InputSource ClockInputSource
-	Drive       OutputDrive
-
-	bus Bus
+	Register      OutputRegister
+	Enabled       bool
+	OEBControlled bool
+	PowerDown     bool
+	IntegerMode   bool
+	Invert        bool
+	PLL           PLLIndex
+	InputSource   ClockInputSource
+	Drive         OutputDrive
+	DisableState  OutputDisableState
+
+	bus         Bus
+	shared      *sharedRegisters
+	mutex       *sync.Mutex
+	unsupported error
 }
 
 // FractionalOutput represents an output that has a fractional frequency divider (CLK0-CLK5).
@@ -37,6 +50,7 @@ type FractionalOutput struct {
 }
 
 // IntegerOutput represents an output that has an integer frequency divider (CLK6-CLK7).
+// The divider must be an even integer between 6 and 254.
 type IntegerOutput struct {
 	Output
 	FrequencyDivider uint8
@@ -47,13 +61,26 @@ type IntegerOutput struct {
 type ClockInputSource uint8
 
 // All possible input sources for an output clock.
+// ClockInputSharedMultisynth uses the Multisynth of CLK0 for the outputs CLK1-CLK3 and the Multisynth of CLK4 for
+// the outputs CLK5-CLK7, it is not available for CLK0 and CLK4.
 const (
 	ClockInputCrystal ClockInputSource = iota
 	ClockInputClkin
-	ClockInputReserved
+	ClockInputSharedMultisynth
 	ClockInputMultisynth
 )
 
+// ClockInputReserved is the former name of ClockInputSharedMultisynth.
+//
+// Deprecated: use ClockInputSharedMultisynth instead.
+const ClockInputReserved = ClockInputSharedMultisynth
+
+// The range of frequencies that can be generated on an output.
+const (
+	MinOutputFrequency = 2500 * Hz
+	MaxOutputFrequency = 200 * MHz
+)
+
 // OutputDrive describes the drive strength of an Output.
 type OutputDrive uint8
 
@@ -96,13 +123,16 @@ var FractionalOutputRegisters = []OutputRegister{
 	{RegClk5Control, RegClk7_4DisableState, 2, RegClk5InitialPhaseOffset, RegMultisynth5Parameters, 0},
 }
 
-func loadFractionalOutputs(bus Bus) []*FractionalOutput {
+func loadFractionalOutputs(bus Bus, shared *sharedRegisters, mutex *sync.Mutex) []*FractionalOutput {
 	result := make([]*FractionalOutput, len(FractionalOutputRegisters))
 	for i, register := range FractionalOutputRegisters {
 		result[i] = &FractionalOutput{
 			Output: Output{
-				Register: register,
-				bus:      bus,
+				Register:      register,
+				OEBControlled: true,
+				bus:           bus,
+				shared:        shared,
+				mutex:         mutex,
 			},
 		}
 	}
@@ -115,21 +145,200 @@ var IntegerOutputRegisters = []OutputRegister{
 	{RegClk7Control, RegClk7_4DisableState, 6, 0, RegMultisynth7Parameters, 4},
 }
 
-func loadIntegerOutputs(bus Bus) []*IntegerOutput {
+// The limits of the Multisynth divider of CLK0-CLK5.
+const (
+	MinMultisynthDivider = 6
+	MaxMultisynthDivider = 1800
+)
+
+// The limits of the integer divider of CLK6 and CLK7.
+const (
+	MinIntegerDivider = 6
+	MaxIntegerDivider = 254
+)
+
+func loadIntegerOutputs(bus Bus, shared *sharedRegisters, mutex *sync.Mutex) []*IntegerOutput {
 	result := make([]*IntegerOutput, len(IntegerOutputRegisters))
 	for i, register := range IntegerOutputRegisters {
 		result[i] = &IntegerOutput{
 			Output: Output{
-				Register: register,
-				bus:      bus,
+				Register:      register,
+				OEBControlled: true,
+				bus:           bus,
+				shared:        shared,
+				mutex:         mutex,
 			},
 		}
 	}
 	return result
 }
 
+// index returns the index of the Output, which is also the Output's bit in the output enable control register.
+func (o *Output) index() OutputIndex {
+	return OutputIndex(o.Register.Control - RegClk0Control)
+}
+
+func (o *Output) load(registers []byte) {
+	o.loadShared(RegOutputEnableControl, registers[RegOutputEnableControl])
+	o.loadShared(RegOebPinEnableControl, registers[RegOebPinEnableControl])
+	o.loadControl(registers[o.Register.Control])
+	o.loadShared(o.Register.DisableState, registers[o.Register.DisableState])
+}
+
+func (o *Output) loadControl(control byte) {
+	o.PowerDown = control&(1<<7) != 0
+	o.IntegerMode = o.index() < Clk6 && control&(1<<6) != 0
+	o.PLL = PLLIndex((control >> 5) & 1)
+	o.Invert = control&(1<<4) != 0
+	o.InputSource = ClockInputSource((control >> 2) & 3)
+	o.Drive = OutputDrive(control & 3)
+}
+
+// loadShared updates the properties of the Output that are stored in the given shared register.
+func (o *Output) loadShared(reg uint8, value byte) {
+	switch reg {
+	case RegOutputEnableControl:
+		o.Enabled = (value>>uint(o.index()))&1 == 0
+	case RegOebPinEnableControl:
+		o.OEBControlled = (value>>uint(o.index()))&1 == 0
+	case o.Register.DisableState:
+		o.DisableState = OutputDisableState((value >> o.Register.DisableStateOffset) & 3)
+	case o.Register.Control:
+		o.loadControl(value)
+	}
+}
+
+func (o *FractionalOutput) load(registers []byte) error {
+	divider, err := parseLoadedRatio(registers[o.Register.Divider : o.Register.Divider+8])
+	if err != nil {
+		return err
+	}
+	o.Output.load(registers)
+	o.FrequencyDivider = divider
+	o.PhaseShift = registers[o.Register.PhaseShift] & 0x7F
+	return nil
+}
+
+func (o *IntegerOutput) load(registers []byte) {
+	o.Output.load(registers)
+	o.FrequencyDivider = registers[o.Register.Divider]
+	o.loadShared(RegClock6_7OutputDivider, registers[RegClock6_7OutputDivider])
+}
+
+// loadShared updates the properties of the IntegerOutput that are stored in the given shared register.
+func (o *IntegerOutput) loadShared(reg uint8, value byte) {
+	o.Output.loadShared(reg, value)
+	if reg == RegClock6_7OutputDivider {
+		o.RDiv = ClockDivider((value >> o.Register.DividerOffset) & 7)
+	}
+}
+
+// Enable enables or disables the Output. Only the Output's bit in the output enable control register is changed,
+// all other outputs keep their state.
+func (o *Output) Enable(enabled bool) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.enable(enabled)
+}
+
+func (o *Output) enable(enabled bool) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
+
+	mask := byte(1 << uint(o.index()))
+	var value byte
+	if !enabled {
+		value = mask
+	}
+
+	err := o.shared.modify(o.bus, RegOutputEnableControl, mask, value)
+	if err == nil {
+		o.Enabled = enabled
+	}
+	return err
+}
+
+// SetOEBControl defines if the Output is enabled and disabled by the OEB pin. Only the Output's bit in the
+// OEB pin enable control register is changed, all other outputs keep their setting.
+func (o *Output) SetOEBControl(controlled bool) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.setOEBControl(controlled)
+}
+
+func (o *Output) setOEBControl(controlled bool) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
+
+	mask := byte(1 << uint(o.index()))
+	var value byte
+	if !controlled {
+		value = mask
+	}
+
+	err := o.shared.modify(o.bus, RegOebPinEnableControl, mask, value)
+	if err == nil {
+		o.OEBControlled = controlled
+	}
+	return err
+}
+
+// SetDisableState sets the state of the Output when it is disabled. Only the Output's bits in the shared disable
+// state register are changed, all other outputs keep their disable state.
+func (o *Output) SetDisableState(state OutputDisableState) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.setDisableState(state)
+}
+
+func (o *Output) setDisableState(state OutputDisableState) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
+
+	mask := byte(3 << o.Register.DisableStateOffset)
+	value := byte(state&3) << o.Register.DisableStateOffset
+
+	err := o.shared.modify(o.bus, o.Register.DisableState, mask, value)
+	if err == nil {
+		o.DisableState = state
+	}
+	return err
+}
+
+// loadSharedControl reads the control register of CLK6 or CLK7 from the device if it is not known yet. The properties
+// of the Output are updated from the register, so they can be used to build the new value of the control register.
+func (o *Output) loadSharedControl() error {
+	if o.index() < Clk6 {
+		return nil
+	}
+	_, err := o.shared.get(o.bus, o.Register.Control)
+	return err
+}
+
+// writeControl writes the given value into the control register of the Output. The control registers of CLK6 and CLK7
+// also contain the integer mode bits of the PLLs (FB_INT), these are kept.
+func (o *Output) writeControl(value byte) error {
+	if o.index() >= Clk6 {
+		return o.shared.modify(o.bus, o.Register.Control, ^byte(1<<6), value)
+	}
+	return writeRegisters(o.bus, o.Register.Control, value)
+}
+
 // SetupControl writes the control register of the Output.
 func (o *Output) SetupControl(powerDown bool, integerMode bool, pll PLLIndex, invert bool, inputSource ClockInputSource, drive OutputDrive) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.setupControl(powerDown, integerMode, pll, invert, inputSource, drive)
+}
+
+func (o *Output) setupControl(powerDown bool, integerMode bool, pll PLLIndex, invert bool, inputSource ClockInputSource, drive OutputDrive) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
+
 	value := byte(pll<<5) | byte(inputSource<<2) | byte(drive)
 	if powerDown {
 		value |= (1 << 7)
@@ -141,21 +350,35 @@ func (o *Output) SetupControl(powerDown bool, integerMode bool, pll PLLIndex, in
 		value |= (1 << 4)
 	}
 
-	o.bus.WriteReg(o.Register.Control, value)
-
-	if o.bus.Err() == nil {
-		o.PowerDown = powerDown
-		o.IntegerMode = integerMode
-		o.PLL = pll
-		o.Invert = invert
-		o.InputSource = inputSource
-		o.Drive = drive
+	if err := o.writeControl(value); err != nil {
+		return err
 	}
-	return o.bus.Err()
+
+	o.PowerDown = powerDown
+	o.IntegerMode = integerMode
+	o.PLL = pll
+	o.Invert = invert
+	o.InputSource = inputSource
+	o.Drive = drive
+	return nil
 }
 
 // SetPowerDown sets the power down flag of the Output and writes it to the output's control register.
 func (o *Output) SetPowerDown(powerDown bool) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.setPowerDown(powerDown)
+}
+
+func (o *Output) setPowerDown(powerDown bool) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
+
+	if err := o.loadSharedControl(); err != nil {
+		return err
+	}
+
 	value := byte(o.PLL<<5) | byte(o.InputSource<<2) | byte(o.Drive)
 	if powerDown {
 		value |= (1 << 7)
@@ -167,17 +390,30 @@ func (o *Output) SetPowerDown(powerDown bool) error {
 		value |= (1 << 4)
 	}
 
-	o.bus.WriteReg(o.Register.Control, value)
-
-	if o.bus.Err() == nil {
-		o.PowerDown = powerDown
+	if err := o.writeControl(value); err != nil {
+		return err
 	}
 
-	return o.bus.Err()
+	o.PowerDown = powerDown
+	return nil
 }
 
 // SetIntegerMode sets the integer mode flag of the Output and writes it to the output's control register.
 func (o *Output) SetIntegerMode(integerMode bool) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.setIntegerMode(integerMode)
+}
+
+func (o *Output) setIntegerMode(integerMode bool) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
+
+	if err := o.loadSharedControl(); err != nil {
+		return err
+	}
+
 	value := byte(o.PLL<<5) | byte(o.InputSource<<2) | byte(o.Drive)
 	if o.PowerDown {
 		value |= (1 << 7)
@@ -189,17 +425,30 @@ func (o *Output) SetIntegerMode(integerMode bool) error {
 		value |= (1 << 4)
 	}
 
-	o.bus.WriteReg(o.Register.Control, value)
-
-	if o.bus.Err() == nil {
-		o.IntegerMode = integerMode
+	if err := o.writeControl(value); err != nil {
+		return err
 	}
 
-	return o.bus.Err()
+	o.IntegerMode = integerMode
+	return nil
 }
 
 // SetPLL sets the PLL of the Output and writes it to the output's control register.
 func (o *Output) SetPLL(pll PLLIndex) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.setPLL(pll)
+}
+
+func (o *Output) setPLL(pll PLLIndex) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
+
+	if err := o.loadSharedControl(); err != nil {
+		return err
+	}
+
 	value := byte(pll<<5) | byte(o.InputSource<<2) | byte(o.Drive)
 	if o.PowerDown {
 		value |= (1 << 7)
@@ -211,17 +460,30 @@ func (o *Output) SetPLL(pll PLLIndex) error {
 		value |= (1 << 4)
 	}
 
-	o.bus.WriteReg(o.Register.Control, value)
-
-	if o.bus.Err() == nil {
-		o.PLL = pll
+	if err := o.writeControl(value); err != nil {
+		return err
 	}
 
-	return o.bus.Err()
+	o.PLL = pll
+	return nil
 }
 
 // SetInvert sets the inversion flag of the Output and writes it to the output's control register.
 func (o *Output) SetInvert(invert bool) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.setInvert(invert)
+}
+
+func (o *Output) setInvert(invert bool) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
+
+	if err := o.loadSharedControl(); err != nil {
+		return err
+	}
+
 	value := byte(o.PLL<<5) | byte(o.InputSource<<2) | byte(o.Drive)
 	if o.PowerDown {
 		value |= (1 << 7)
@@ -233,17 +495,30 @@ func (o *Output) SetInvert(invert bool) error {
 		value |= (1 << 4)
 	}
 
-	o.bus.WriteReg(o.Register.Control, value)
-
-	if o.bus.Err() == nil {
-		o.Invert = invert
+	if err := o.writeControl(value); err != nil {
+		return err
 	}
 
-	return o.bus.Err()
+	o.Invert = invert
+	return nil
 }
 
 // SetInputSource sets the clock input source of the Output and writes it to the output's control register.
 func (o *Output) SetInputSource(inputSource ClockInputSource) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.setInputSource(inputSource)
+}
+
+func (o *Output) setInputSource(inputSource ClockInputSource) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
+
+	if err := o.loadSharedControl(); err != nil {
+		return err
+	}
+
 	value := byte(o.PLL<<5) | byte(inputSource<<2) | byte(o.Drive)
 	if o.PowerDown {
 		value |= (1 << 7)
@@ -255,17 +530,30 @@ func (o *Output) SetInputSource(inputSource ClockInputSource) error {
 		value |= (1 << 4)
 	}
 
-	o.bus.WriteReg(o.Register.Control, value)
-
-	if o.bus.Err() == nil {
-		o.InputSource = inputSource
+	if err := o.writeControl(value); err != nil {
+		return err
 	}
 
-	return o.bus.Err()
+	o.InputSource = inputSource
+	return nil
 }
 
 // SetDrive sets the output drive strength of the Output and writes it to the output's control register.
 func (o *Output) SetDrive(drive OutputDrive) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.setDrive(drive)
+}
+
+func (o *Output) setDrive(drive OutputDrive) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
+
+	if err := o.loadSharedControl(); err != nil {
+		return err
+	}
+
 	value := byte(o.PLL<<5) | byte(o.InputSource<<2) | byte(drive)
 	if o.PowerDown {
 		value |= (1 << 7)
@@ -277,31 +565,109 @@ func (o *Output) SetDrive(drive OutputDrive) error {
 		value |= (1 << 4)
 	}
 
-	o.bus.WriteReg(o.Register.Control, value)
-
-	if o.bus.Err() == nil {
-		o.Drive = drive
+	if err := o.writeControl(value); err != nil {
+		return err
 	}
 
-	return o.bus.Err()
+	o.Drive = drive
+	return nil
 }
 
-// SetupDivider writes the frequency divider into the registers.
+// SetupDivider writes the frequency divider into the registers. The integer mode of the Output is selected
+// automatically: it is enabled for even integer dividers and disabled otherwise.
 func (o *FractionalOutput) SetupDivider(divider FractionalRatio) error {
-	divider.WriteTo(o.bus.RegWriter(o.Register.Divider))
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.setupDivider(divider)
+}
+
+func (o *FractionalOutput) setupDivider(divider FractionalRatio) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
 
-	if o.bus.Err() == nil {
-		o.FrequencyDivider = divider
+	if err := writeRegisters(o.bus, o.Register.Divider, divider.Bytes()...); err != nil {
+		return err
 	}
-	return o.bus.Err()
+	o.FrequencyDivider = divider
+	if o.IntegerMode != divider.IsInteger() {
+		return o.setIntegerMode(divider.IsInteger())
+	}
+	return nil
 }
 
 // SetupPhaseShift sets the phase shift of the Clock.
 func (o *FractionalOutput) SetupPhaseShift(phaseShift uint8) error {
-	o.bus.WriteReg(o.Register.PhaseShift, byte(phaseShift&0x7F))
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.setupPhaseShift(phaseShift)
+}
+
+func (o *FractionalOutput) setupPhaseShift(phaseShift uint8) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
+
+	if err := writeRegisters(o.bus, o.Register.PhaseShift, byte(phaseShift&0x7F)); err != nil {
+		return err
+	}
+	o.PhaseShift = phaseShift
+	return nil
+}
+
+// SetupDivider writes the integer frequency divider and the R divider into the registers.
+// The divider must be an even integer between 6 and 254.
+func (o *IntegerOutput) SetupDivider(divider uint8, rDiv ClockDivider) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.setupDivider(divider, rDiv)
+}
+
+func (o *IntegerOutput) setupDivider(divider uint8, rDiv ClockDivider) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
+
+	if divider%2 == 1 || divider < MinIntegerDivider || divider > MaxIntegerDivider {
+		return fmt.Errorf("invalid integer divider %d, must be even and within %d-%d", divider, MinIntegerDivider, MaxIntegerDivider)
+	}
+
+	if err := writeRegisters(o.bus, o.Register.Divider, divider); err != nil {
+		return err
+	}
+	o.FrequencyDivider = divider
+
+	err := o.shared.modify(o.bus, RegClock6_7OutputDivider, 7<<o.Register.DividerOffset, byte(rDiv&7)<<o.Register.DividerOffset)
+	if err == nil {
+		o.RDiv = rDiv
+	}
+	return err
+}
+
+// Divide the given frequency by the divider and the R divider of this output.
+func (o *IntegerOutput) Divide(base Frequency) Frequency {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.divide(base)
+}
+
+func (o *IntegerOutput) divide(base Frequency) Frequency {
+	if o.FrequencyDivider == 0 {
+		return 0
+	}
+	return base / (Frequency(o.FrequencyDivider) * Frequency(o.RDiv.Factor()))
+}
+
+// DivideExact divides the given frequency by the divider and the R divider of this output without any rounding.
+func (o *IntegerOutput) DivideExact(base *big.Rat) *big.Rat {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.divideExact(base)
+}
 
-	if o.bus.Err() == nil {
-		o.PhaseShift = phaseShift
+func (o *IntegerOutput) divideExact(base *big.Rat) *big.Rat {
+	if o.FrequencyDivider == 0 {
+		return new(big.Rat)
 	}
-	return o.bus.Err()
+	return new(big.Rat).Quo(base, new(big.Rat).SetInt64(int64(o.FrequencyDivider)*int64(o.RDiv.Factor())))
 }
@@ -0,0 +1,216 @@
+package si5351
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// MaxPhaseShift is the largest value of the phase offset register of an output.
+const MaxPhaseShift = 0x7F
+
+// ErrPhaseOutOfRange indicates that a phase offset cannot be generated with the phase offset register and the inversion
+// of an output.
+var ErrPhaseOutOfRange = errors.New("phase offset out of range")
+
+// PhaseOffset describes the requested phase of an output relative to the reference output of a phase group.
+type PhaseOffset struct {
+	Output OutputIndex
+	// Degrees is the phase offset in degrees, negative values are taken modulo 360°.
+	Degrees float64
+	// Picoseconds is the phase offset as time delay. If it is not zero, it is used instead of Degrees.
+	Picoseconds float64
+}
+
+// Phase describes the phase offset that is actually generated on an output.
+type Phase struct {
+	Output      OutputIndex
+	PhaseShift  uint8
+	Invert      bool
+	Degrees     float64
+	Picoseconds float64
+}
+
+// SetupPhaseGroup sets the phase offsets of the given outputs relative to the reference output. All outputs of the group
+// must be driven by the same PLL with the same divider, set up the frequency of the outputs first.
+// The phase offset register delays an output in steps of a quarter of the PLL's period, up to 127 steps. Offsets of 180°
+// and more additionally use the inversion of the output, this allows e.g. 0°/90°/180°/270° groups for double balanced
+// mixers. If necessary, the reference output is delayed or inverted as well.
+// The PLL is reset to apply the phase offsets. The method returns the achieved phase of each output, starting with
+// the reference. If an offset exceeds the range of the phase offset register, SetupPhaseGroup returns an error that
+// wraps ErrPhaseOutOfRange.
+func (s *Si5351) SetupPhaseGroup(reference OutputIndex, offsets ...PhaseOffset) ([]Phase, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	outputs := []OutputIndex{reference}
+	for _, offset := range offsets {
+		outputs = append(outputs, offset.Output)
+	}
+	if err := s.checkPhaseGroup(outputs); err != nil {
+		return nil, err
+	}
+
+	ref := s.fractionalOutput[reference]
+	divider := ref.FrequencyDivider.Rat()
+	divider.Mul(divider, new(big.Rat).SetInt64(int64(ref.FrequencyDivider.ClockDivider.Factor())))
+	steps, _ := divider.Float64()
+	steps *= 4 // phase offset steps per period of the output
+	pllFrequency, _ := s.exactPLLFrequency(ref.PLL).Float64()
+	outputFrequency := pllFrequency * 4 / steps
+
+	requested := make([]float64, len(offsets))
+	for i, offset := range offsets {
+		requested[i] = offset.Degrees
+		if offset.Picoseconds != 0 {
+			requested[i] = offset.Picoseconds * 1e-12 * outputFrequency * 360
+		}
+	}
+	settings, err := findPhaseSettings(requested, steps)
+	if err != nil {
+		return nil, fmt.Errorf("CLK%d: %w", offsets[len(settings)-1].Output, err)
+	}
+
+	result := make([]Phase, len(outputs))
+	for i, setting := range settings {
+		degrees := normalizeDegrees(setting.degrees(steps) - settings[0].degrees(steps))
+		result[i] = Phase{
+			Output:      outputs[i],
+			PhaseShift:  setting.shift,
+			Invert:      setting.invert,
+			Degrees:     degrees,
+			Picoseconds: degrees / 360 / outputFrequency * 1e12,
+		}
+	}
+
+	for _, phase := range result {
+		o := s.fractionalOutput[phase.Output]
+		if err := o.setupPhaseShift(phase.PhaseShift); err != nil {
+			return nil, err
+		}
+		if err := o.setInvert(phase.Invert); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.pll[ref.PLL].reset(); err != nil {
+		return nil, err
+	}
+
+	return result, s.awaitLock(ref.PLL)
+}
+
+func (s *Si5351) checkPhaseGroup(outputs []OutputIndex) error {
+	if err := s.checkOutputs(outputs...); err != nil {
+		return err
+	}
+	used := make(map[OutputIndex]bool)
+	for _, output := range outputs {
+		if int(output) >= len(s.fractionalOutput) {
+			return fmt.Errorf("only CLK0-CLK5 support a phase shift: %w", ErrUnsupportedOutput)
+		}
+		if used[output] {
+			return fmt.Errorf("CLK%d is used more than once in the phase group", output)
+		}
+		used[output] = true
+	}
+
+	ref := s.fractionalOutput[outputs[0]]
+	if ref.FrequencyDivider.Rat().Sign() == 0 {
+		return fmt.Errorf("the divider of CLK%d is not set up", outputs[0])
+	}
+	for _, output := range outputs[1:] {
+		o := s.fractionalOutput[output]
+		if o.PLL != ref.PLL || o.FrequencyDivider != ref.FrequencyDivider {
+			return fmt.Errorf("CLK%d must use the same PLL and divider as CLK%d", output, outputs[0])
+		}
+	}
+	return nil
+}
+
+// phaseSetting is the setting of an output that generates a certain phase: the value of the phase offset register
+// and the inversion.
+type phaseSetting struct {
+	shift  uint8
+	invert bool
+}
+
+// degrees returns the phase of this setting in degrees, with the given number of phase offset steps per period.
+func (p phaseSetting) degrees(steps float64) float64 {
+	result := float64(p.shift) / steps * 360
+	if p.invert {
+		result += 180
+	}
+	return result
+}
+
+// findPhaseSettings finds the settings for a reference output and outputs with the given phase offsets relative to
+// the reference, with the given number of phase offset steps per period. Some offsets can only be generated if
+// the reference output is delayed or inverted, too. The first setting belongs to the reference output.
+// If no settings are found, the result contains the settings up to the first output that is out of range.
+func findPhaseSettings(offsets []float64, steps float64) ([]phaseSetting, error) {
+	var best []phaseSetting
+	bestError := math.Inf(1)
+	var firstAttempt []phaseSetting
+	for _, invert := range []bool{false, true} {
+		for shift := 0; shift <= MaxPhaseShift && float64(shift) < steps/2; shift++ {
+			reference := phaseSetting{shift: uint8(shift), invert: invert}
+			settings := []phaseSetting{reference}
+			var totalError float64
+			for _, offset := range offsets {
+				setting, e, ok := findPhaseSetting(reference.degrees(steps)+offset, steps)
+				if !ok {
+					break
+				}
+				settings = append(settings, setting)
+				totalError += e
+			}
+			if firstAttempt == nil {
+				firstAttempt = settings
+			}
+			if len(settings) == len(offsets)+1 && totalError < bestError-1e-9 {
+				best = settings
+				bestError = totalError
+			}
+		}
+	}
+	if best == nil {
+		maxDegrees := MaxPhaseShift / steps * 360
+		return firstAttempt, fmt.Errorf("the phase offset must be within 0°-%.1f° or 180°-%.1f°, one step is %.3f°: %w", maxDegrees, maxDegrees+180, 360/steps, ErrPhaseOutOfRange)
+	}
+	return best, nil
+}
+
+// findPhaseSetting finds the setting that generates the given absolute phase in degrees with the smallest error,
+// with the given number of phase offset steps per period.
+func findPhaseSetting(degrees float64, steps float64) (setting phaseSetting, deviation float64, ok bool) {
+	deviation = math.Inf(1)
+	for _, invert := range []bool{false, true} {
+		remaining := degrees
+		if invert {
+			remaining -= 180
+		}
+		remaining = normalizeDegrees(remaining)
+		if remaining > 180 {
+			remaining -= 360
+		}
+		shift := math.Round(remaining / 360 * steps)
+		if shift < 0 || shift > MaxPhaseShift {
+			continue
+		}
+		e := math.Abs(shift/steps*360 - remaining)
+		if e < deviation {
+			setting, deviation, ok = phaseSetting{shift: uint8(shift), invert: invert}, e, true
+		}
+	}
+	return
+}
+
+// normalizeDegrees returns the given phase in the range 0°-360°.
+func normalizeDegrees(degrees float64) float64 {
+	result := math.Mod(degrees, 360)
+	if result < 0 {
+		result += 360
+	}
+	return result
+}
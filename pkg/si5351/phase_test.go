@@ -0,0 +1,113 @@
+package si5351
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupPhaseTestDevice(frequency Frequency, outputs ...OutputIndex) (*Si5351, *fakeBus) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.SetupPLL(PLLA, 900*MHz)
+	device.PrepareOutputs(PLLA, false, ClockInputMultisynth, OutputDrive2mA, OutputDisableLow, outputs...)
+	for _, output := range outputs {
+		device.SetOutputFrequency(output, frequency)
+	}
+	return device, bus
+}
+
+func TestSetupPhaseGroupFourPhases(t *testing.T) {
+	device, bus := setupPhaseTestDevice(10*MHz, Clk0, Clk1, Clk2, Clk3)
+
+	phases, err := device.SetupPhaseGroup(Clk0,
+		PhaseOffset{Output: Clk1, Degrees: 90},
+		PhaseOffset{Output: Clk2, Degrees: 180},
+		PhaseOffset{Output: Clk3, Degrees: 270},
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Phase{
+		{Output: Clk0, PhaseShift: 0, Invert: false, Degrees: 0, Picoseconds: 0},
+		{Output: Clk1, PhaseShift: 90, Invert: false, Degrees: 90, Picoseconds: 25000},
+		{Output: Clk2, PhaseShift: 0, Invert: true, Degrees: 180, Picoseconds: 50000},
+		{Output: Clk3, PhaseShift: 90, Invert: true, Degrees: 270, Picoseconds: 75000},
+	}, phases)
+	assert.Equal(t, byte(90), bus.registers[RegClk3InitialPhaseOffset])
+	assert.True(t, device.Clk3().Invert)
+	assert.Equal(t, byte(1<<4), bus.registers[RegClk3Control]&(1<<4))
+}
+
+func TestSetupPhaseGroupThreePhases(t *testing.T) {
+	testCases := []struct {
+		frequency Frequency
+		shifts    []uint8
+		inverts   []bool
+	}{
+		{25 * MHz, []uint8{0, 48, 24}, []bool{false, false, true}},
+		{15 * MHz, []uint8{0, 80, 40}, []bool{false, false, true}},
+		{10 * MHz, []uint8{0, 120, 60}, []bool{false, false, true}},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%.0f", tc.frequency), func(t *testing.T) {
+			device, _ := setupPhaseTestDevice(tc.frequency, Clk0, Clk1, Clk2)
+
+			phases, err := device.SetupPhaseGroup(Clk0,
+				PhaseOffset{Output: Clk1, Degrees: 120},
+				PhaseOffset{Output: Clk2, Degrees: -120},
+			)
+
+			assert.NoError(t, err)
+			for i, phase := range phases {
+				assert.Equal(t, tc.shifts[i], phase.PhaseShift, "CLK%d", i)
+				assert.Equal(t, tc.inverts[i], phase.Invert, "CLK%d", i)
+				assert.InDelta(t, float64(i)*120, phase.Degrees, 1e-9, "CLK%d", i)
+			}
+		})
+	}
+}
+
+func TestSetupPhaseGroupDelaysReference(t *testing.T) {
+	device, _ := setupPhaseTestDevice(10*MHz, Clk0, Clk1)
+
+	phases, err := device.SetupPhaseGroup(Clk0, PhaseOffset{Output: Clk1, Degrees: -10})
+
+	assert.NoError(t, err)
+	assert.Equal(t, Phase{Output: Clk0, PhaseShift: 10}, phases[0])
+	assert.Equal(t, uint8(0), phases[1].PhaseShift)
+	assert.False(t, phases[1].Invert)
+	assert.InDelta(t, 350, phases[1].Degrees, 1e-9)
+}
+
+func TestSetupPhaseGroupPicoseconds(t *testing.T) {
+	device, _ := setupPhaseTestDevice(10*MHz, Clk0, Clk1)
+
+	phases, err := device.SetupPhaseGroup(Clk0, PhaseOffset{Output: Clk1, Picoseconds: 1000})
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(4), phases[1].PhaseShift)
+	assert.InDelta(t, 4.0, phases[1].Degrees, 1e-9)
+	assert.InDelta(t, 1111.111, phases[1].Picoseconds, 1e-3)
+}
+
+func TestSetupPhaseGroupOutOfRange(t *testing.T) {
+	device, _ := setupPhaseTestDevice(3*MHz, Clk0, Clk1, Clk2)
+
+	_, err := device.SetupPhaseGroup(Clk0, PhaseOffset{Output: Clk1, Degrees: 120}, PhaseOffset{Output: Clk2, Degrees: 240})
+
+	assert.True(t, errors.Is(err, ErrPhaseOutOfRange), "%v", err)
+}
+
+func TestSetupPhaseGroupInvalidGroup(t *testing.T) {
+	device, _ := setupPhaseTestDevice(10*MHz, Clk0, Clk1)
+	device.SetOutputFrequency(Clk2, 7*MHz)
+
+	_, err := device.SetupPhaseGroup(Clk0, PhaseOffset{Output: Clk2, Degrees: 90})
+	assert.Error(t, err)
+	_, err = device.SetupPhaseGroup(Clk0, PhaseOffset{Output: Clk6, Degrees: 90})
+	assert.Error(t, err)
+	_, err = device.SetupPhaseGroup(Clk0, PhaseOffset{Output: Clk0, Degrees: 90})
+	assert.Error(t, err)
+}
@@ -0,0 +1,430 @@
+package si5351
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Target describes a frequency that should be generated on an output.
+type Target struct {
+	Output    OutputIndex
+	Frequency Frequency
+	// Tolerance is the maximum deviation of the generated frequency in Hz. If the tolerance is zero,
+	// the closest possible frequency is generated.
+	Tolerance Frequency
+	// IntegerOnly requests an even integer Multisynth divider for lower jitter.
+	IntegerOnly bool
+}
+
+func (t Target) String() string {
+	return fmt.Sprintf("CLK%d %.3fHz", t.Output, t.Frequency)
+}
+
+// Plan describes the configuration of the PLLs and the outputs that generates a set of targets.
+type Plan struct {
+	PLLs    [2]PLLPlan
+	Outputs []OutputPlan
+}
+
+// PLLPlan describes the planned configuration of a PLL.
+type PLLPlan struct {
+	Used       bool
+	Multiplier FractionalRatio
+	Tuning     Tuning
+}
+
+// OutputPlan describes the planned configuration of an output. For CLK6 and CLK7, A of the divider is the integer
+// divider and the ClockDivider of the divider is the R divider. IntegerMode indicates if the Multisynth of CLK0-CLK5
+// runs in integer mode.
+type OutputPlan struct {
+	Target
+	PLL         PLLIndex
+	Divider     FractionalRatio
+	IntegerMode bool
+	Tuning      Tuning
+}
+
+// PlanError explains why a set of targets cannot be generated.
+type PlanError struct {
+	Targets []Target
+	Reason  string
+}
+
+func (e *PlanError) Error() string {
+	targets := make([]string, len(e.Targets))
+	for i, target := range e.Targets {
+		targets[i] = target.String()
+	}
+	return fmt.Sprintf("cannot generate %s: %s", strings.Join(targets, ", "), e.Reason)
+}
+
+// planEpsilon is the deviation in Hz up to which an integer divider generates a target with zero tolerance.
+const planEpsilon = 1e-6 * Hz
+
+// PlanFrequencies finds a configuration of both PLLs and the Multisynths that generates the given targets.
+// The planner distributes the targets on PLL A and PLL B. Targets that need an integer divider (IntegerOnly, CLK6 and CLK7,
+// frequencies above 150MHz) must share a common PLL frequency, all other targets use fractional dividers.
+// PLL frequencies that are an even integer multiple of the reference frequency are preferred, as they allow to run
+// the PLL in integer mode.
+// A target frequency out of the range of the outputs is rejected with a *RangeError that wraps ErrOutputOutOfRange.
+// If the targets cannot be generated at the same time, PlanFrequencies returns a *PlanError.
+func (s *Si5351) PlanFrequencies(targets ...Target) (*Plan, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.checkTargets(targets); err != nil {
+		return nil, err
+	}
+
+	var integer, fractional []Target
+	for _, target := range targets {
+		if target.needsIntegerDivider() {
+			integer = append(integer, target)
+		} else {
+			fractional = append(fractional, target)
+		}
+	}
+
+	refFrequencies := [2]Frequency{s.referenceFrequency(PLLA), s.referenceFrequency(PLLB)}
+	var best *assignment
+	for mask := 0; mask < 1<<uint(len(integer)); mask++ {
+		candidate := newAssignment(integer, fractional, mask, refFrequencies)
+		if candidate == nil {
+			continue
+		}
+		if best == nil || candidate.betterThan(best) {
+			best = candidate
+		}
+	}
+	if best == nil {
+		return nil, explainPlanFailure(integer, fractional)
+	}
+
+	return s.buildPlan(targets, best)
+}
+
+func (s *Si5351) checkTargets(targets []Target) error {
+	if len(targets) == 0 {
+		return &PlanError{Reason: "no targets given"}
+	}
+	if len(targets) > 8 {
+		return &PlanError{Targets: targets, Reason: "the Si5351 has at most 8 outputs"}
+	}
+	used := make(map[OutputIndex]bool)
+	for _, target := range targets {
+		if err := s.Variant.checkOutput(target.Output); err != nil {
+			return err
+		}
+		if used[target.Output] {
+			return &PlanError{Targets: []Target{target}, Reason: fmt.Sprintf("CLK%d is used more than once", target.Output)}
+		}
+		used[target.Output] = true
+		if err := checkOutputFrequency(target.Frequency); err != nil {
+			return fmt.Errorf("cannot plan CLK%d: %w", target.Output, err)
+		}
+	}
+	return nil
+}
+
+func (t Target) needsIntegerDivider() bool {
+	return t.IntegerOnly || t.Output >= Clk6 || t.Frequency > MinBy4Frequency
+}
+
+// usesBy4 indicates if this target needs the divide-by-4 mode of the Multisynth. CLK6 and CLK7 do not support it.
+func (t Target) usesBy4() bool {
+	return t.Output < Clk6 && t.Frequency > MinBy4Frequency
+}
+
+// integerDividerRange returns the range of even integer dividers that can be used to generate this target.
+func (t Target) integerDividerRange() (min, max uint32) {
+	if t.Output >= Clk6 {
+		return MinIntegerDivider, MaxIntegerDivider
+	}
+	return MinMultisynthDivider, MaxMultisynthDivider
+}
+
+// vcoCandidates returns all PLL frequencies that are an even integer multiple of this target, highest first.
+func (t Target) vcoCandidates() []Frequency {
+	if t.usesBy4() {
+		vco := 4 * t.Frequency
+		if vco < MinPLLFrequency || vco > MaxPLLFrequency {
+			return nil
+		}
+		return []Frequency{vco}
+	}
+
+	minD, maxD := t.integerDividerRange()
+	unique := make(map[Frequency]bool)
+	var result []Frequency
+	for r := ClockBy1; r <= ClockBy128; r++ {
+		f := t.Frequency * Frequency(r.Factor())
+		first := uint32(math.Ceil(float64(MinPLLFrequency / f)))
+		if first%2 == 1 {
+			first++
+		}
+		if first < minD {
+			first = minD
+		}
+		for d := first; d <= maxD && Frequency(d)*f <= MaxPLLFrequency; d += 2 {
+			vco := Frequency(d) * f
+			if !unique[vco] {
+				unique[vco] = true
+				result = append(result, vco)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] > result[j] })
+	return result
+}
+
+// integerDivider finds the even integer divider and R divider that generates the frequency closest to this target
+// from the given PLL frequency. The result is only valid if the deviation is within the tolerance.
+func (t Target) integerDivider(vco Frequency) (divider FractionalRatio, deviation Frequency, valid bool) {
+	if t.usesBy4() {
+		divider = FractionalRatio{A: 4, B: 0, C: 1, By4: true}
+		deviation = Frequency(math.Abs(float64(vco/4 - t.Frequency)))
+		return divider, deviation, t.accepts(deviation)
+	}
+
+	minD, maxD := t.integerDividerRange()
+	deviation = -1
+	for r := ClockBy1; r <= ClockBy128; r++ {
+		q := float64(vco / (t.Frequency * Frequency(r.Factor())))
+		d := 2 * uint32(q/2+0.5)
+		if d < minD || d > maxD {
+			continue
+		}
+		e := Frequency(math.Abs(float64(vco/(Frequency(d)*Frequency(r.Factor())) - t.Frequency)))
+		if deviation < 0 || e < deviation {
+			divider = FractionalRatio{A: d, B: 0, C: 1, ClockDivider: r}
+			deviation = e
+		}
+	}
+	if deviation < 0 {
+		return FractionalRatio{}, 0, false
+	}
+	return divider, deviation, t.accepts(deviation)
+}
+
+// fitsFractional indicates if this target can be generated with a fractional divider from the given PLL frequency.
+func (t Target) fitsFractional(vco Frequency) bool {
+	q := vco / t.Frequency
+	return q >= MinMultisynthDivider && q <= MaxMultisynthDivider*Frequency(ClockBy128.Factor())
+}
+
+func (t Target) accepts(deviation Frequency) bool {
+	return deviation <= planEpsilon || deviation <= t.Tolerance
+}
+
+// assignment is a candidate distribution of the targets on both PLLs.
+type assignment struct {
+	vco       [2]Frequency
+	used      [2]bool
+	pll       map[OutputIndex]PLLIndex
+	deviation Frequency
+}
+
+func newAssignment(integer, fractional []Target, mask int, refFrequencies [2]Frequency) *assignment {
+	result := &assignment{pll: make(map[OutputIndex]PLLIndex)}
+
+	var groups [2][]Target
+	for i, target := range integer {
+		pll := PLLIndex((mask >> uint(i)) & 1)
+		groups[pll] = append(groups[pll], target)
+		result.pll[target.Output] = pll
+	}
+	for pll, group := range groups {
+		if len(group) == 0 {
+			result.vco[pll] = defaultVCO(refFrequencies[pll])
+			continue
+		}
+		vco, deviation, ok := commonVCO(group, refFrequencies[pll])
+		if !ok {
+			return nil
+		}
+		result.vco[pll] = vco
+		result.used[pll] = true
+		result.deviation += deviation
+	}
+
+	for _, target := range fractional {
+		pll := PLLA
+		if !target.fitsFractional(result.vco[PLLA]) {
+			pll = PLLB
+		}
+		if !target.fitsFractional(result.vco[pll]) {
+			return nil
+		}
+		result.pll[target.Output] = pll
+		result.used[pll] = true
+	}
+	return result
+}
+
+// commonVCO finds the PLL frequency that generates all given targets with integer dividers and the smallest total deviation.
+// If several PLL frequencies are equally good, an even integer multiple of the reference frequency is preferred.
+func commonVCO(group []Target, refFrequency Frequency) (vco Frequency, deviation Frequency, ok bool) {
+	for _, candidate := range group[0].vcoCandidates() {
+		var total Frequency
+		valid := true
+		for _, target := range group {
+			_, d, v := target.integerDivider(candidate)
+			if !v {
+				valid = false
+				break
+			}
+			total += d
+		}
+		if !valid {
+			continue
+		}
+		better := !ok || total < deviation-planEpsilon
+		equal := ok && total <= deviation+planEpsilon
+		if better || (equal && isEvenMultiple(candidate, refFrequency) && !isEvenMultiple(vco, refFrequency)) {
+			vco, deviation, ok = candidate, total, true
+		}
+	}
+	return
+}
+
+// defaultVCO returns the PLL frequency for PLLs that only drive fractional dividers: the highest even integer multiple
+// of the reference frequency within the range of the PLL.
+func defaultVCO(refFrequency Frequency) Frequency {
+	if refFrequency <= 0 {
+		return MaxPLLFrequency
+	}
+	for q := math.Floor(float64(MaxPLLFrequency / refFrequency)); Frequency(q)*refFrequency >= MinPLLFrequency; q-- {
+		if math.Mod(q, 2) == 0 {
+			return Frequency(q) * refFrequency
+		}
+	}
+	return MaxPLLFrequency
+}
+
+// isEvenMultiple indicates if the given PLL frequency is an even integer multiple of the given reference frequency.
+func isEvenMultiple(vco, refFrequency Frequency) bool {
+	q := float64(vco / refFrequency)
+	return math.Abs(q-2*math.Round(q/2)) < 1e-9
+}
+
+func (a *assignment) usedPLLs() int {
+	result := 0
+	for _, used := range a.used {
+		if used {
+			result++
+		}
+	}
+	return result
+}
+
+func (a *assignment) betterThan(other *assignment) bool {
+	if math.Abs(float64(a.deviation-other.deviation)) > float64(planEpsilon) {
+		return a.deviation < other.deviation
+	}
+	return a.usedPLLs() < other.usedPLLs()
+}
+
+func explainPlanFailure(integer, fractional []Target) error {
+	for _, target := range integer {
+		if len(target.vcoCandidates()) == 0 {
+			return &PlanError{Targets: []Target{target}, Reason: fmt.Sprintf("no even integer divider generates this frequency from a PLL frequency within %.0fHz-%.0fHz", MinPLLFrequency, MaxPLLFrequency)}
+		}
+	}
+	for _, target := range fractional {
+		if !target.fitsFractional(MaxPLLFrequency) {
+			return &PlanError{Targets: []Target{target}, Reason: "the frequency is out of the range of the Multisynth and R dividers"}
+		}
+	}
+	if len(integer) > 0 {
+		return &PlanError{Targets: integer, Reason: "these targets need integer dividers, but there is no distribution on PLL A and PLL B with a common PLL frequency for each PLL"}
+	}
+	return &PlanError{Targets: fractional, Reason: "no PLL frequency is suitable for these targets"}
+}
+
+func (s *Si5351) buildPlan(targets []Target, a *assignment) (*Plan, error) {
+	result := &Plan{}
+	for i := range result.PLLs {
+		if !a.used[i] {
+			continue
+		}
+		pll := PLLIndex(i)
+		multiplier, err := FindFractionalMultiplier(s.referenceFrequency(pll), a.vco[i])
+		if err != nil {
+			return nil, err
+		}
+		result.PLLs[i] = PLLPlan{
+			Used:       true,
+			Multiplier: multiplier,
+			Tuning:     Tuning{Requested: a.vco[i], Achieved: multiplier.MultiplyExact(s.exactReferenceFrequency(pll))},
+		}
+	}
+
+	for _, target := range targets {
+		pll := a.pll[target.Output]
+		pllTuning := result.PLLs[pll].Tuning
+		output := OutputPlan{Target: target, PLL: pll}
+		if target.needsIntegerDivider() {
+			output.Divider, _, _ = target.integerDivider(a.vco[pll])
+		} else {
+			divider, err := FindFractionalDivider(pllTuning.Frequency(), target.Frequency)
+			if err != nil {
+				return nil, err
+			}
+			output.Divider = divider
+		}
+		output.IntegerMode = target.Output < Clk6 && output.Divider.IsInteger()
+		output.Tuning = Tuning{Requested: target.Frequency, Achieved: output.Divider.DivideExact(pllTuning.Achieved)}
+
+		deviation, _ := output.Tuning.Deviation().Float64()
+		if target.Tolerance > 0 && Frequency(math.Abs(deviation)) > target.Tolerance {
+			return nil, &PlanError{Targets: []Target{target}, Reason: fmt.Sprintf("the closest possible frequency %v is out of the tolerance of %.3fHz", output.Tuning, target.Tolerance)}
+		}
+		result.Outputs = append(result.Outputs, output)
+	}
+
+	return result, nil
+}
+
+// ApplyPlan configures the PLLs and the Multisynths of the outputs according to the given plan and resets the used PLLs.
+// The control parameters of the outputs, like the drive strength or the disable state, are not changed.
+// Use PrepareOutputs to set them up.
+func (s *Si5351) ApplyPlan(plan *Plan) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var usedPLLs []PLLIndex
+	for i, p := range plan.PLLs {
+		if !p.Used {
+			continue
+		}
+		if err := s.pll[i].setupMultiplier(p.Multiplier); err != nil {
+			return err
+		}
+		usedPLLs = append(usedPLLs, PLLIndex(i))
+	}
+
+	for _, output := range plan.Outputs {
+		if err := s.Output(output.Output).setPLL(output.PLL); err != nil {
+			return err
+		}
+		if output.Output >= Clk6 {
+			if err := s.integerOutput[output.Output-Clk6].setupDivider(uint8(output.Divider.A), output.Divider.ClockDivider); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.fractionalOutput[output.Output].setupDivider(output.Divider); err != nil {
+			return err
+		}
+	}
+
+	for _, pll := range usedPLLs {
+		if err := s.pll[pll].reset(); err != nil {
+			return err
+		}
+	}
+
+	return s.awaitLock(usedPLLs...)
+}
@@ -0,0 +1,155 @@
+package si5351
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanFrequenciesFractional(t *testing.T) {
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, new(fakeBus))
+
+	plan, err := device.PlanFrequencies(
+		Target{Output: Clk0, Frequency: 7074 * KHz},
+		Target{Output: Clk1, Frequency: 14074 * KHz},
+	)
+
+	assert.NoError(t, err)
+	assert.True(t, plan.PLLs[PLLA].Used)
+	assert.False(t, plan.PLLs[PLLB].Used)
+	assert.Equal(t, MaxPLLFrequency, plan.PLLs[PLLA].Tuning.Frequency())
+	for _, output := range plan.Outputs {
+		assert.Equal(t, PLLA, output.PLL)
+		assert.False(t, output.IntegerMode)
+		deviation, _ := output.Tuning.Deviation().Float64()
+		assert.True(t, math.Abs(deviation) < 0.01, "%v", output.Tuning)
+	}
+}
+
+func TestPlanFrequenciesIntegerOnBothPLLs(t *testing.T) {
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, new(fakeBus))
+
+	plan, err := device.PlanFrequencies(
+		Target{Output: Clk0, Frequency: 10 * MHz, IntegerOnly: true},
+		Target{Output: Clk1, Frequency: 7074 * KHz},
+		Target{Output: Clk6, Frequency: 7372800 * Hz},
+		Target{Output: Clk7, Frequency: 25 * MHz},
+	)
+
+	assert.NoError(t, err)
+	assert.True(t, plan.PLLs[PLLA].Used)
+	assert.True(t, plan.PLLs[PLLB].Used)
+	assert.NotEqual(t, plan.Outputs[0].PLL, plan.Outputs[2].PLL)
+	assert.Equal(t, plan.Outputs[0].PLL, plan.Outputs[3].PLL)
+	assert.True(t, plan.Outputs[0].IntegerMode)
+	assert.True(t, plan.Outputs[0].Divider.IsInteger())
+	assert.Equal(t, uint32(0), plan.Outputs[2].Divider.B)
+	for _, output := range plan.Outputs {
+		deviation, _ := output.Tuning.Deviation().Float64()
+		assert.True(t, math.Abs(deviation) < 0.01, "%v: %v", output.Target, output.Tuning)
+	}
+}
+
+func TestPlanFrequenciesBy4(t *testing.T) {
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, new(fakeBus))
+
+	plan, err := device.PlanFrequencies(
+		Target{Output: Clk0, Frequency: 180 * MHz},
+		Target{Output: Clk2, Frequency: 7 * MHz},
+	)
+
+	assert.NoError(t, err)
+	assert.True(t, plan.Outputs[0].Divider.By4)
+	assert.True(t, plan.Outputs[0].IntegerMode)
+	assert.Equal(t, 720*MHz, plan.PLLs[plan.Outputs[0].PLL].Tuning.Frequency())
+	assert.Equal(t, 180*MHz, plan.Outputs[0].Tuning.Frequency())
+}
+
+func TestPlanFrequenciesTolerance(t *testing.T) {
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, new(fakeBus))
+
+	_, err := device.PlanFrequencies(
+		Target{Output: Clk6, Frequency: 10 * MHz},
+		Target{Output: Clk7, Frequency: 7372800 * Hz},
+		Target{Output: Clk0, Frequency: 12345678 * Hz, IntegerOnly: true},
+	)
+	var planErr *PlanError
+	assert.True(t, errors.As(err, &planErr), "%v", err)
+
+	plan, err := device.PlanFrequencies(
+		Target{Output: Clk6, Frequency: 10 * MHz},
+		Target{Output: Clk7, Frequency: 7372800 * Hz},
+		Target{Output: Clk0, Frequency: 12345678 * Hz, IntegerOnly: true, Tolerance: 50 * KHz},
+	)
+	assert.NoError(t, err)
+	deviation, _ := plan.Outputs[2].Tuning.Deviation().Float64()
+	assert.True(t, math.Abs(deviation) <= 50000, "%v", plan.Outputs[2].Tuning)
+}
+
+func TestPlanFrequenciesInvalidTargets(t *testing.T) {
+	device := New(Si5351A10MSOP, Crystal{BaseFrequency: Crystal25MHz}, new(fakeBus))
+	var planErr *PlanError
+
+	_, err := device.PlanFrequencies(Target{Output: Clk3, Frequency: 10 * MHz})
+	assert.True(t, errors.Is(err, ErrUnsupportedOutput))
+
+	_, err = device.PlanFrequencies(Target{Output: Clk0, Frequency: 10 * MHz}, Target{Output: Clk0, Frequency: 5 * MHz})
+	assert.True(t, errors.As(err, &planErr))
+
+	_, err = device.PlanFrequencies(Target{Output: Clk1, Frequency: 250 * MHz})
+	assert.True(t, errors.Is(err, ErrOutputOutOfRange), "%v", err)
+
+	_, err = device.PlanFrequencies(Target{Output: Clk0, Frequency: 10 * MHz}, Target{Output: Clk1, Frequency: 2 * KHz})
+	var rangeErr *RangeError
+	assert.True(t, errors.As(err, &rangeErr), "%v", err)
+	assert.Equal(t, MinOutputFrequency, rangeErr.Min)
+
+	device = New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, new(fakeBus))
+	_, err = device.PlanFrequencies(Target{Output: Clk7, Frequency: 180 * MHz})
+	assert.True(t, errors.As(err, &planErr))
+	assert.Equal(t, Clk7, planErr.Targets[0].Output)
+}
+
+func TestApplyPlan(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	plan, err := device.PlanFrequencies(
+		Target{Output: Clk0, Frequency: 10 * MHz, IntegerOnly: true},
+		Target{Output: Clk6, Frequency: 7372800 * Hz},
+	)
+	assert.NoError(t, err)
+
+	err = device.ApplyPlan(plan)
+	assert.NoError(t, err)
+
+	loaded := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	assert.NoError(t, loaded.Load())
+	for _, pll := range []PLLIndex{PLLA, PLLB} {
+		assert.Equal(t, plan.PLLs[pll].Multiplier, loaded.PLL(pll).Multiplier)
+	}
+	assert.Equal(t, plan.Outputs[0].PLL, loaded.Clk0().PLL)
+	assert.Equal(t, plan.Outputs[0].Divider, loaded.Clk0().FrequencyDivider)
+	assert.True(t, loaded.Clk0().IntegerMode)
+	assert.Equal(t, plan.Outputs[1].PLL, loaded.Clk6().PLL)
+	assert.Equal(t, uint8(plan.Outputs[1].Divider.A), loaded.Clk6().FrequencyDivider)
+	assert.Equal(t, plan.Outputs[1].Divider.ClockDivider, loaded.Clk6().RDiv)
+}
+
+func TestPlanFrequenciesPrefersIntegerPLL(t *testing.T) {
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal27MHz}, new(fakeBus))
+
+	plan, err := device.PlanFrequencies(Target{Output: Clk0, Frequency: 9 * MHz, IntegerOnly: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, FractionalRatio{A: 32, B: 0, C: 1}, plan.PLLs[PLLA].Multiplier)
+	assert.Equal(t, FractionalRatio{A: 96, B: 0, C: 1}, plan.Outputs[0].Divider)
+	assert.True(t, plan.Outputs[0].IntegerMode)
+
+	plan, err = device.PlanFrequencies(Target{Output: Clk1, Frequency: 7074 * KHz})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 864*MHz, plan.PLLs[PLLA].Tuning.Frequency())
+	assert.True(t, plan.PLLs[PLLA].Multiplier.IsInteger())
+}
@@ -1,5 +1,10 @@
 package si5351
 
+import (
+	"fmt"
+	"sync"
+)
+
 // PLLIndex indicates one of both PLLs.
 type PLLIndex int
 
@@ -9,13 +14,40 @@ const (
 	PLLB
 )
 
+func (p PLLIndex) String() string {
+	switch p {
+	case PLLA:
+		return "A"
+	case PLLB:
+		return "B"
+	default:
+		return fmt.Sprintf("%d", int(p))
+	}
+}
+
+// The limits of the PLL frequency.
+const (
+	MinPLLFrequency = 600 * MHz
+	MaxPLLFrequency = 900 * MHz
+)
+
+// The limits of the PLL multiplier.
+const (
+	MinPLLMultiplier = 15
+	MaxPLLMultiplier = 90
+)
+
 // PLL represents a PLL of the Si5351.
 type PLL struct {
-	Register    PLLRegister
-	InputSource PLLInputSource
-	Multiplier  FractionalRatio
+	Register       PLLRegister
+	InputSource    PLLInputSource
+	Multiplier     FractionalRatio
+	IntegerMode    bool
+	SpreadSpectrum SpreadSpectrum
 
-	bus Bus
+	bus    Bus
+	shared *sharedRegisters
+	mutex  *sync.Mutex
 }
 
 // PLLInputSource describes the input source of a PLL.
@@ -32,37 +64,98 @@ type PLLRegister struct {
 	Multiplier        uint8
 	ResetOffset       uint8
 	InputSourceOffset uint8
+	SpreadSpectrum    uint8 // 0 = spread spectrum is not supported
+	IntegerMode       uint8 // the register that contains the FB_INT bit
 }
 
 // PLLRegisters contains the register descriptions of all PLLs.
 var PLLRegisters = []PLLRegister{
-	{RegPLLAMultisynthParameters, 5, 2},
-	{RegPLLBMultisynthParameters, 7, 3},
+	{RegPLLAMultisynthParameters, 5, 2, RegSpreadSpectrumParameters, RegClk6Control},
+	{RegPLLBMultisynthParameters, 7, 3, 0, RegClk7Control},
 }
 
-func loadPLLs(bus Bus) []*PLL {
+func loadPLLs(bus Bus, shared *sharedRegisters, mutex *sync.Mutex) []*PLL {
 	result := make([]*PLL, len(PLLRegisters))
 	for i, register := range PLLRegisters {
 		result[i] = &PLL{
 			Register: register,
 			bus:      bus,
+			shared:   shared,
+			mutex:    mutex,
 		}
 	}
 	return result
 }
 
-// SetupMultiplier writes the frequency multiplier into the registers and resets the PLL.
+func (p *PLL) load(registers []byte) error {
+	multiplier, err := parseLoadedRatio(registers[p.Register.Multiplier : p.Register.Multiplier+8])
+	if err != nil {
+		return err
+	}
+	p.InputSource = PLLInputSource((registers[RegPLLInputSource] >> p.Register.InputSourceOffset) & 1)
+	p.Multiplier = multiplier
+	p.loadShared(p.Register.IntegerMode, registers[p.Register.IntegerMode])
+	return nil
+}
+
+// loadShared updates the properties of the PLL that are stored in the given shared register.
+func (p *PLL) loadShared(reg uint8, value byte) {
+	if reg == p.Register.IntegerMode {
+		p.IntegerMode = value&(1<<6) != 0
+	}
+}
+
+// SetupMultiplier writes the frequency multiplier into the registers. The integer mode of the PLL is selected
+// automatically: it is enabled for even integer multipliers and disabled otherwise.
 func (p *PLL) SetupMultiplier(multiplier FractionalRatio) error {
-	multiplier.WriteTo(p.bus.RegWriter(p.Register.Multiplier))
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.setupMultiplier(multiplier)
+}
+
+func (p *PLL) setupMultiplier(multiplier FractionalRatio) error {
+	// the current integer mode must be known to decide if FB_INT needs to be changed
+	if _, err := p.shared.get(p.bus, p.Register.IntegerMode); err != nil {
+		return err
+	}
+	if err := writeRegisters(p.bus, p.Register.Multiplier, multiplier.Bytes()...); err != nil {
+		return err
+	}
+	p.Multiplier = multiplier
+	if p.IntegerMode != multiplier.IsInteger() {
+		return p.setIntegerMode(multiplier.IsInteger())
+	}
+	return nil
+}
+
+// SetIntegerMode sets the integer mode (FB_INT) of the PLL's feedback Multisynth. The integer mode lowers the jitter,
+// it must only be enabled if the multiplier is an even integer.
+// The FB_INT bit is located in the control register of CLK6 (PLL A) or CLK7 (PLL B), the other bits are not changed.
+func (p *PLL) SetIntegerMode(integerMode bool) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.setIntegerMode(integerMode)
+}
 
-	if p.bus.Err() == nil {
-		p.Multiplier = multiplier
+func (p *PLL) setIntegerMode(integerMode bool) error {
+	var value byte
+	if integerMode {
+		value = 1 << 6
 	}
-	return p.bus.Err()
+	err := p.shared.modify(p.bus, p.Register.IntegerMode, 1<<6, value)
+	if err == nil {
+		p.IntegerMode = integerMode
+	}
+	return err
 }
 
 // Reset the PLL.
 func (p *PLL) Reset() error {
-	p.bus.WriteReg(RegPLLReset, (1 << p.Register.ResetOffset))
-	return p.bus.Err()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.reset()
+}
+
+func (p *PLL) reset() error {
+	return writeRegisters(p.bus, RegPLLReset, (1 << p.Register.ResetOffset))
 }
@@ -0,0 +1,117 @@
+package si5351
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxQuadratureDivider is the largest even integer divider that allows a phase shift of 90°: the phase offset register
+// delays an output by steps of a quarter of the PLL's period, 90° need as many steps as the divider, and the register
+// holds at most 127 steps.
+const MaxQuadratureDivider = 126
+
+// ErrQuadratureOutOfRange indicates that a frequency cannot be generated as quadrature signal.
+var ErrQuadratureOutOfRange = errors.New("quadrature frequency out of range")
+
+// QuadratureRangeError describes the range of frequencies that can be generated as quadrature signal.
+// It matches ErrQuadratureOutOfRange with errors.Is.
+type QuadratureRangeError struct {
+	Frequency    Frequency
+	MinFrequency Frequency
+	MaxFrequency Frequency
+}
+
+func (e *QuadratureRangeError) Error() string {
+	return fmt.Sprintf("cannot generate a quadrature signal at %.0fHz, the range is %.0fHz-%.0fHz", e.Frequency, e.MinFrequency, e.MaxFrequency)
+}
+
+// Is indicates if the target is ErrQuadratureOutOfRange.
+func (e *QuadratureRangeError) Is(target error) bool {
+	return target == ErrQuadratureOutOfRange
+}
+
+// MinQuadratureFrequency returns the lowest frequency that can be generated as quadrature signal with the given
+// minimum PLL frequency.
+func MinQuadratureFrequency(minPLLFrequency Frequency) Frequency {
+	return minPLLFrequency / MaxQuadratureDivider
+}
+
+// FindQuadratureRatios calculates a pair of ratios that allows to generate the given frequency as quadrature signal:
+// the divider is an even integer of at most 126 and the PLL frequency is within minPLLFrequency and 900MHz.
+// Frequencies above 150MHz are generated using the divide-by-4 mode of the Multisynth.
+// The phase offset is a time delay of at most 127/(4 * PLL frequency), it does not scale with the R divider.
+// Therefore the lowest frequency is limited by the lowest PLL frequency, R dividers cannot extend the range.
+// If the frequency is out of reach, FindQuadratureRatios returns a *QuadratureRangeError.
+func FindQuadratureRatios(refFrequency, frequency, minPLLFrequency Frequency) (multiplier, divider FractionalRatio, err error) {
+	if frequency > MinBy4Frequency && frequency <= MaxOutputFrequency {
+		return FindFractionalMultiplierWithBy4Divider(refFrequency, frequency)
+	}
+
+	minFrequency := MinQuadratureFrequency(minPLLFrequency)
+	if frequency < minFrequency || frequency > MaxOutputFrequency {
+		return FractionalRatio{}, FractionalRatio{}, &QuadratureRangeError{Frequency: frequency, MinFrequency: minFrequency, MaxFrequency: MaxOutputFrequency}
+	}
+
+	// use the highest PLL frequency for the best phase resolution
+	return findEvenIntegerRatios(refFrequency, frequency, MaxQuadratureDivider)
+}
+
+// ExternalQuadratureDivider describes an external divider that generates a quadrature signal from a local oscillator
+// (LO) at a multiple of the frequency. It covers the frequencies below the range of the phase offset register,
+// e.g. the 160m and 80m bands.
+type ExternalQuadratureDivider uint32
+
+// The external quadrature dividers.
+const (
+	// NoExternalQuadratureDivider indicates that there is no external quadrature divider.
+	NoExternalQuadratureDivider ExternalQuadratureDivider = 0
+	// QuadratureBy2 is a pair of flip-flops that divide by two, one is clocked by the LO at twice the frequency on the
+	// phase output, the other one by the inverted LO on the quadrature output. The inverted LO delays the second
+	// flip-flop by a quarter of the period of the divided signal.
+	QuadratureBy2 ExternalQuadratureDivider = 2
+	// QuadratureBy4 is a Johnson counter of two flip-flops that divides by four, clocked by the LO at four times
+	// the frequency on the phase output. The quadrature output carries the inverted LO, it may stay unconnected.
+	QuadratureBy4 ExternalQuadratureDivider = 4
+)
+
+// FindExternalQuadratureRatios calculates a pair of ratios that generate the LO for the given external quadrature divider,
+// so that the external divider generates the given frequency as quadrature signal. The divider is an even integer of
+// at most 1800 and the PLL frequency is within minPLLFrequency and 900MHz. The LO does not use the phase offset register,
+// therefore it is not limited by the range of the phase offset register.
+// If the frequency is out of reach, FindExternalQuadratureRatios returns a *QuadratureRangeError.
+func FindExternalQuadratureRatios(refFrequency, frequency, minPLLFrequency Frequency, external ExternalQuadratureDivider) (multiplier, divider FractionalRatio, err error) {
+	if external == NoExternalQuadratureDivider {
+		return FractionalRatio{}, FractionalRatio{}, errors.New("no external quadrature divider")
+	}
+
+	minFrequency := minPLLFrequency / (MaxMultisynthDivider * Frequency(external))
+	maxFrequency := MinBy4Frequency / Frequency(external)
+	if frequency < minFrequency || frequency > maxFrequency {
+		return FractionalRatio{}, FractionalRatio{}, &QuadratureRangeError{Frequency: frequency, MinFrequency: minFrequency, MaxFrequency: maxFrequency}
+	}
+
+	return findEvenIntegerRatios(refFrequency, frequency*Frequency(external), MaxMultisynthDivider)
+}
+
+// findEvenIntegerRatios calculates a pair of ratios with an even integer divider of at most maxDivider and the highest
+// possible PLL frequency. The caller ensures that the PLL frequency does not fall below its minimum.
+func findEvenIntegerRatios(refFrequency, frequency Frequency, maxDivider uint32) (multiplier, divider FractionalRatio, err error) {
+	a := 2 * uint32(MaxPLLFrequency/(2*frequency))
+	if a > maxDivider {
+		a = maxDivider
+	}
+	multiplier, err = FindFractionalMultiplier(refFrequency, frequency*Frequency(a))
+	if err != nil {
+		return FractionalRatio{}, FractionalRatio{}, err
+	}
+	divider = FractionalRatio{A: a, B: 0, C: 1}
+	return multiplier, divider, nil
+}
+
+// quadratureMinPLLFrequency returns the lowest PLL frequency that SetupQuadratureOutput may use for the given PLL.
+func (s *Si5351) quadratureMinPLLFrequency(pll PLLIndex) Frequency {
+	if !s.AllowLowPLLFrequency {
+		return MinPLLFrequency
+	}
+	return MinPLLMultiplier * s.referenceFrequency(pll)
+}
@@ -0,0 +1,124 @@
+package si5351
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetupQuadratureOutputHFRange(t *testing.T) {
+	for f := 1800; f <= 30000; f += 100 {
+		frequency := Frequency(f) * KHz
+		t.Run(fmt.Sprintf("%.0f", frequency), func(t *testing.T) {
+			bus := new(fakeBus)
+			device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+
+			pllTuning, outputTuning, err := device.SetupQuadratureOutput(PLLA, Clk0, Clk1, frequency)
+
+			if frequency < 600*MHz/MaxQuadratureDivider {
+				assert.True(t, errors.Is(err, ErrQuadratureOutOfRange), "%v", err)
+				return
+			}
+			assert.NoError(t, err)
+			divider := device.Clk1().FrequencyDivider
+			assert.True(t, divider.IsInteger())
+			assert.True(t, divider.A <= MaxQuadratureDivider)
+			assert.Equal(t, ClockBy1, divider.ClockDivider)
+			assert.Equal(t, uint8(divider.A), device.Clk1().PhaseShift)
+			assert.Equal(t, byte(divider.A), bus.registers[RegClk1InitialPhaseOffset])
+			assert.Equal(t, uint8(0), device.Clk0().PhaseShift)
+			assert.True(t, pllTuning.Frequency() >= MinPLLFrequency && pllTuning.Frequency() <= MaxPLLFrequency, "%v", pllTuning)
+			deviation, _ := outputTuning.Deviation().Float64()
+			assert.True(t, math.Abs(deviation) < 0.01, "%v", outputTuning)
+		})
+	}
+}
+
+func TestSetupQuadratureOutputExternalDividerHFRange(t *testing.T) {
+	for f := 1800; f <= 30000; f += 100 {
+		frequency := Frequency(f) * KHz
+		t.Run(fmt.Sprintf("%.0f", frequency), func(t *testing.T) {
+			bus := new(fakeBus)
+			device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+			device.ExternalQuadratureDivider = QuadratureBy4
+
+			pllTuning, outputTuning, err := device.SetupQuadratureOutput(PLLA, Clk0, Clk1, frequency)
+
+			assert.NoError(t, err)
+			assert.True(t, pllTuning.Frequency() >= MinPLLFrequency && pllTuning.Frequency() <= MaxPLLFrequency, "%v", pllTuning)
+			deviation, _ := outputTuning.Deviation().Float64()
+			assert.True(t, math.Abs(deviation) < 0.01, "%v", outputTuning)
+			divider := device.Clk1().FrequencyDivider
+			assert.Equal(t, device.Clk0().FrequencyDivider, divider)
+			assert.True(t, divider.IsInteger())
+			assert.False(t, device.Clk0().Invert)
+			if frequency >= 600*MHz/MaxQuadratureDivider {
+				assert.Equal(t, uint32(0), outputTuning.ExternalDivider)
+				assert.Equal(t, uint8(divider.A), device.Clk1().PhaseShift)
+				assert.False(t, device.Clk1().Invert)
+				return
+			}
+			assert.Equal(t, uint32(4), outputTuning.ExternalDivider)
+			assert.Equal(t, 4*frequency, divider.Divide(pllTuning.Frequency()))
+			assert.Equal(t, uint8(0), device.Clk1().PhaseShift)
+			assert.True(t, device.Clk1().Invert)
+			assert.Equal(t, byte(1<<4), bus.registers[RegClk1Control]&(1<<4))
+		})
+	}
+}
+
+func TestSetupQuadratureOutputExternalDividerBy2(t *testing.T) {
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, new(fakeBus))
+	device.ExternalQuadratureDivider = QuadratureBy2
+
+	_, outputTuning, err := device.SetupQuadratureOutput(PLLA, Clk0, Clk1, 1840*KHz)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1840*KHz, outputTuning.Frequency())
+	assert.Equal(t, uint32(2), outputTuning.ExternalDivider)
+	assert.Equal(t, 3680*KHz, device.Clk1().FrequencyDivider.Divide(device.PLLA().Multiplier.Multiply(25*MHz)))
+	assert.True(t, device.Clk1().Invert)
+
+	_, outputTuning, err = device.SetupQuadratureOutput(PLLA, Clk0, Clk1, 7074*KHz)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), outputTuning.ExternalDivider)
+	assert.False(t, device.Clk1().Invert)
+
+	_, _, err = device.SetupQuadratureOutput(PLLA, Clk0, Clk1, 100*KHz)
+
+	var rangeErr *QuadratureRangeError
+	assert.True(t, errors.As(err, &rangeErr), "%v", err)
+	assert.Equal(t, 600*MHz/(MaxMultisynthDivider*2), rangeErr.MinFrequency)
+}
+
+func TestSetupQuadratureOutputLowPLLFrequency(t *testing.T) {
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, new(fakeBus))
+	device.AllowLowPLLFrequency = true
+
+	pllTuning, _, err := device.SetupQuadratureOutput(PLLA, Clk0, Clk1, 3500*KHz)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 441*MHz, pllTuning.Frequency())
+	assert.Equal(t, uint8(126), device.Clk1().PhaseShift)
+
+	_, _, err = device.SetupQuadratureOutput(PLLA, Clk0, Clk1, 1800*KHz)
+
+	var rangeErr *QuadratureRangeError
+	assert.True(t, errors.As(err, &rangeErr))
+	assert.Equal(t, 375*MHz/MaxQuadratureDivider, rangeErr.MinFrequency)
+}
+
+func TestSetupQuadratureOutputBy4(t *testing.T) {
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, new(fakeBus))
+
+	_, outputTuning, err := device.SetupQuadratureOutput(PLLA, Clk0, Clk1, 180*MHz)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 180*MHz, outputTuning.Frequency())
+	assert.True(t, device.Clk1().FrequencyDivider.By4)
+	assert.Equal(t, uint8(4), device.Clk1().PhaseShift)
+}
@@ -0,0 +1,157 @@
+package si5351
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Rat returns the value of this frequency as exact rational number. The value is taken from the shortest decimal
+// representation of the frequency, i.e. 7074000.3Hz is exactly 70740003/10Hz.
+func (f Frequency) Rat() *big.Rat {
+	result, ok := new(big.Rat).SetString(fmt.Sprintf("%g", float64(f)))
+	if !ok {
+		return new(big.Rat)
+	}
+	return result
+}
+
+// ExactFrequency is the corrected frequency of this Crystal as exact rational number.
+func (c Crystal) ExactFrequency() *big.Rat {
+	return correctedFrequency(c.BaseFrequency, c.CorrectionPPM)
+}
+
+// ExactFrequency is the corrected frequency of this reference clock as exact rational number.
+func (c Clkin) ExactFrequency() *big.Rat {
+	return correctedFrequency(c.BaseFrequency, c.CorrectionPPM)
+}
+
+func correctedFrequency(base Frequency, correctionPPM int) *big.Rat {
+	correction := big.NewRat(int64(1000000+correctionPPM), 1000000)
+	return correction.Mul(correction, base.Rat())
+}
+
+// Rat returns the exact value of this ratio (A + B/C) as rational number. The R divider is not included.
+func (d *FractionalRatio) Rat() *big.Rat {
+	if d.C == 0 {
+		return new(big.Rat).SetInt64(int64(d.A))
+	}
+	return new(big.Rat).SetFrac64(int64(d.A)*int64(d.C)+int64(d.B), int64(d.C))
+}
+
+// MultiplyExact multiplies this ratio with the given base frequency without any rounding.
+func (d *FractionalRatio) MultiplyExact(base *big.Rat) *big.Rat {
+	result := d.Rat()
+	return result.Mul(result, base)
+}
+
+// DivideExact divides the given frequency by this ratio and the R divider without any rounding.
+// If the ratio is zero, DivideExact returns zero.
+func (d *FractionalRatio) DivideExact(base *big.Rat) *big.Rat {
+	divisor := d.Rat()
+	if divisor.Sign() == 0 {
+		return new(big.Rat)
+	}
+	divisor.Mul(divisor, new(big.Rat).SetInt64(int64(d.ClockDivider.Factor())))
+	return divisor.Quo(base, divisor)
+}
+
+// ApproximateRatio finds the best rational approximation A + B/C of the given non-negative value with C <= maxC.
+// The approximation is calculated from the continued fraction expansion of the value: it is either a convergent or
+// a semiconvergent, no other fraction with a denominator <= maxC is closer to the value.
+func ApproximateRatio(x *big.Rat, maxC uint32) FractionalRatio {
+	if maxC == 0 {
+		maxC = 1
+	}
+	a, n := new(big.Int).QuoRem(x.Num(), x.Denom(), new(big.Int))
+	d := new(big.Int).Set(x.Denom())
+	fraction := new(big.Rat).SetFrac(n, d)
+
+	// the convergents of the fraction n/d = [0; t1, t2, ...]
+	h0, k0 := uint64(1), uint64(0)
+	h1, k1 := uint64(0), uint64(1)
+	t, r := new(big.Int), new(big.Int)
+	for n.Sign() != 0 {
+		t.QuoRem(d, n, r)
+		if !t.IsUint64() || t.Uint64() > uint64(maxC) || t.Uint64()*k1+k0 > uint64(maxC) {
+			// the next convergent exceeds maxC, check the largest semiconvergent that fits
+			s := (uint64(maxC) - k0) / k1
+			if s > 0 {
+				h, k := s*h1+h0, s*k1+k0
+				if distance(fraction, h, k).Cmp(distance(fraction, h1, k1)) < 0 {
+					h1, k1 = h, k
+				}
+			}
+			break
+		}
+		h0, h1 = h1, t.Uint64()*h1+h0
+		k0, k1 = k1, t.Uint64()*k1+k0
+		d, n, r = n, r, d
+	}
+
+	if h1 >= k1 {
+		// the fraction was rounded up to 1
+		a.Add(a, big.NewInt(1))
+		h1, k1 = 0, 1
+	}
+	return FractionalRatio{A: uint32(a.Uint64()), B: uint32(h1), C: uint32(k1)}
+}
+
+// distance returns |x - h/k|.
+func distance(x *big.Rat, h, k uint64) *big.Rat {
+	result := new(big.Rat).SetFrac(new(big.Int).SetUint64(h), new(big.Int).SetUint64(k))
+	result.Sub(x, result)
+	return result.Abs(result)
+}
+
+// Tuning describes the result of setting a frequency: the requested frequency and the exact frequency that is
+// actually generated by the Si5351, based on the exact reference frequency.
+type Tuning struct {
+	// Requested is the requested frequency. It is zero if no specific frequency was requested, e.g. with SetOutputDivider.
+	Requested Frequency
+	// Achieved is the exact achieved frequency in Hz.
+	Achieved *big.Rat
+	// ExternalDivider is the ratio of an external divider that generates the achieved frequency from the output, e.g.
+	// an ExternalQuadratureDivider. The output runs at ExternalDivider times the achieved frequency.
+	// ExternalDivider is zero if the output generates the achieved frequency directly.
+	ExternalDivider uint32
+}
+
+// Frequency returns the achieved frequency, rounded to the nearest Frequency value.
+func (t Tuning) Frequency() Frequency {
+	if t.Achieved == nil {
+		return 0
+	}
+	result, _ := t.Achieved.Float64()
+	return Frequency(result)
+}
+
+// Deviation returns the exact difference between the achieved and the requested frequency in Hz.
+func (t Tuning) Deviation() *big.Rat {
+	result := new(big.Rat)
+	if t.Achieved == nil || t.Requested == 0 {
+		return result
+	}
+	return result.Sub(t.Achieved, t.Requested.Rat())
+}
+
+// ErrorPPB returns the deviation of the achieved frequency from the requested frequency in parts per billion.
+func (t Tuning) ErrorPPB() float64 {
+	if t.Requested == 0 {
+		return 0
+	}
+	result := t.Deviation()
+	result.Mul(result, big.NewRat(1000000000, 1))
+	result.Quo(result, t.Requested.Rat())
+	ppb, _ := result.Float64()
+	return ppb
+}
+
+func (t Tuning) String() string {
+	if t.Achieved == nil {
+		return "-"
+	}
+	if t.ExternalDivider != 0 {
+		return fmt.Sprintf("%sHz (%+.3fppb) from the output divided by %d", t.Achieved.FloatString(3), t.ErrorPPB(), t.ExternalDivider)
+	}
+	return fmt.Sprintf("%sHz (%+.3fppb)", t.Achieved.FloatString(3), t.ErrorPPB())
+}
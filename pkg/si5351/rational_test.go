@@ -0,0 +1,83 @@
+package si5351
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrequencyRat(t *testing.T) {
+	assert.Equal(t, big.NewRat(25000000, 1), (25 * MHz).Rat())
+	assert.Equal(t, big.NewRat(70740003, 10), Frequency(7074000.3).Rat())
+	assert.Equal(t, big.NewRat(25000750, 1), Crystal{BaseFrequency: Crystal25MHz, CorrectionPPM: 30}.ExactFrequency())
+}
+
+func TestApproximateRatio(t *testing.T) {
+	testCases := []struct {
+		value    *big.Rat
+		maxC     uint32
+		expected FractionalRatio
+	}{
+		{big.NewRat(36, 1), maxDenominator, FractionalRatio{A: 36, B: 0, C: 1}},
+		{big.NewRat(900000000, 7074000), maxDenominator, FractionalRatio{A: 127, B: 89, C: 393}},
+		{big.NewRat(314159265, 100000000), 7, FractionalRatio{A: 3, B: 1, C: 7}},
+		{big.NewRat(314159265, 100000000), 113, FractionalRatio{A: 3, B: 16, C: 113}},
+		{big.NewRat(199999, 100000), 1000, FractionalRatio{A: 2, B: 0, C: 1}},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%v/%d", tc.value, tc.maxC), func(t *testing.T) {
+			assert.Equal(t, tc.expected, ApproximateRatio(tc.value, tc.maxC))
+		})
+	}
+}
+
+func TestApproximateRatioIsBest(t *testing.T) {
+	const maxC = 50
+	for n := int64(1); n < 2000; n += 7 {
+		value := big.NewRat(n, 997)
+		actual := ApproximateRatio(value, maxC)
+		actualDistance := new(big.Rat).Sub(value, actual.Rat())
+		actualDistance.Abs(actualDistance)
+		for c := int64(1); c <= maxC; c++ {
+			b := new(big.Int).Quo(new(big.Int).Mul(value.Num(), big.NewInt(c)), value.Denom()).Int64()
+			for _, candidate := range []*big.Rat{big.NewRat(b, c), big.NewRat(b+1, c)} {
+				distance := new(big.Rat).Sub(value, candidate)
+				distance.Abs(distance)
+				assert.True(t, actualDistance.Cmp(distance) <= 0, "%v: %v is closer than %v", value, candidate, actual)
+			}
+		}
+	}
+}
+
+func TestTuningDigitalModes(t *testing.T) {
+	crystal := Crystal{BaseFrequency: Crystal25MHz, CorrectionPPM: 17}
+	dialFrequencies := []Frequency{1836600, 3568600, 7038600, 10138700, 14095600, 1840000, 3573000, 7074000, 10136000, 14074000, 21074000, 28074000}
+	for _, dial := range dialFrequencies {
+		for offset := Frequency(1400); offset <= 1600; offset += 0.5 {
+			frequency := dial + offset
+			device := New(Si5351A20QFN, crystal, new(fakeBus))
+			device.SetupPLL(PLLA, 900*MHz)
+
+			tuning, err := device.SetOutputFrequency(Clk0, frequency)
+
+			assert.NoError(t, err)
+			deviation, _ := tuning.Deviation().Float64()
+			assert.True(t, deviation > -0.1 && deviation < 0.1, "%.1fHz: %v", frequency, tuning)
+		}
+	}
+}
+
+func TestTuning(t *testing.T) {
+	tuning := Tuning{Requested: 10 * MHz, Achieved: big.NewRat(100000001, 10)}
+
+	assert.Equal(t, Frequency(10000000.1), tuning.Frequency())
+	assert.Equal(t, big.NewRat(1, 10), tuning.Deviation())
+	assert.InDelta(t, 10.0, tuning.ErrorPPB(), 1e-9)
+	assert.Equal(t, "10000000.100Hz (+10.000ppb)", tuning.String())
+	assert.Equal(t, Frequency(0), Tuning{}.Frequency())
+
+	tuning.ExternalDivider = 4
+	assert.Equal(t, "10000000.100Hz (+10.000ppb) from the output divided by 4", tuning.String())
+}
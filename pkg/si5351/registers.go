@@ -1,5 +1,11 @@
 package si5351
 
+import (
+	"fmt"
+
+	"github.com/ftl/i2c"
+)
+
 // All registers of the Si5351.
 const (
 	RegDeviceStatus                   = 0
@@ -29,6 +35,8 @@ const (
 	RegMultisynth6Parameters          = 90
 	RegMultisynth7Parameters          = 91
 	RegClock6_7OutputDivider          = 92
+	RegSpreadSpectrumParameters       = 149
+	RegVCXOParameters                 = 162
 	RegClk0InitialPhaseOffset         = 165
 	RegClk1InitialPhaseOffset         = 166
 	RegClk2InitialPhaseOffset         = 167
@@ -37,4 +45,119 @@ const (
 	RegClk5InitialPhaseOffset         = 170
 	RegPLLReset                       = 177
 	RegCrystalInternalLoadCapacitance = 183
+	RegFanoutEnable                   = 187
 )
+
+// registerBlock describes a contiguous range of registers.
+type registerBlock struct {
+	first, last uint8
+}
+
+// registerBlocks contains all register ranges that describe the configuration of the Si5351.
+var registerBlocks = []registerBlock{
+	{RegInterruptStatusMask, RegOutputEnableControl},
+	{RegOebPinEnableControl, RegOebPinEnableControl},
+	{RegPLLInputSource, RegClock6_7OutputDivider},
+	{RegClk0InitialPhaseOffset, RegClk5InitialPhaseOffset},
+	{RegCrystalInternalLoadCapacitance, RegCrystalInternalLoadCapacitance},
+	{RegFanoutEnable, RegFanoutEnable},
+}
+
+// sharedRegisters holds a shadow copy of the registers that contain bits of more than one PLL, output, or fanout.
+// This allows to change the bits of one PLL or output without touching the others. A register that is not known yet
+// is read from the device before its bits are changed for the first time.
+type sharedRegisters struct {
+	values map[uint8]byte
+	// loaded is called with the value of a register that was read from the device.
+	loaded func(reg uint8, value byte)
+}
+
+func newSharedRegisters() *sharedRegisters {
+	return &sharedRegisters{values: make(map[uint8]byte)}
+}
+
+// sharedRegisterAddresses contains all registers that are shared between several PLLs or outputs.
+var sharedRegisterAddresses = []uint8{
+	RegOutputEnableControl,
+	RegOebPinEnableControl,
+	RegClk3_0DisableState,
+	RegClk7_4DisableState,
+	RegClock6_7OutputDivider,
+	RegClk6Control,
+	RegClk7Control,
+	RegFanoutEnable,
+}
+
+func (r *sharedRegisters) load(registers []byte) {
+	for _, reg := range sharedRegisterAddresses {
+		r.values[reg] = registers[reg]
+	}
+}
+
+// get returns the value of the given register. If the register is not known yet, it is read from the device.
+func (r *sharedRegisters) get(bus Bus, reg uint8) (byte, error) {
+	if value, ok := r.values[reg]; ok {
+		return value, nil
+	}
+
+	value := make([]byte, 1)
+	if err := readRegisters(bus, reg, value); err != nil {
+		return 0, err
+	}
+	r.values[reg] = value[0]
+	if r.loaded != nil {
+		r.loaded(reg, value[0])
+	}
+	return value[0], nil
+}
+
+// set stores the given value of the given register after it was written to the device.
+func (r *sharedRegisters) set(reg uint8, value byte) {
+	r.values[reg] = value
+}
+
+// modify replaces the bits selected by mask in the given register with the corresponding bits of value
+// and writes the result to the bus. If not all bits are replaced, the other bits are read from the device first,
+// unless the register is already known.
+func (r *sharedRegisters) modify(bus Bus, reg uint8, mask byte, value byte) error {
+	var current byte
+	if mask != 0xFF {
+		var err error
+		current, err = r.get(bus, reg)
+		if err != nil {
+			return err
+		}
+	}
+
+	newValue := (current &^ mask) | (value & mask)
+	if err := writeRegisters(bus, reg, newValue); err != nil {
+		return err
+	}
+	r.set(reg, newValue)
+	return nil
+}
+
+// writeRegisters writes the given values to the bus, starting at the given register.
+// An error of the bus is wrapped with the address of the register.
+func writeRegisters(bus Bus, reg uint8, values ...byte) error {
+	if _, err := bus.WriteReg(reg, values...); err != nil {
+		return fmt.Errorf("cannot write register %d: %w", reg, err)
+	}
+	return nil
+}
+
+// readRegisters reads len(p) registers from the bus into p, starting at the given register.
+// An error of the bus is wrapped with the address of the register.
+// The ReadReg method of *i2c.I2C does not return the values it has read, therefore *i2c.I2C is read like an I2CBus.
+func readRegisters(bus Bus, reg uint8, p []byte) error {
+	var err error
+	if device, ok := bus.(*i2c.I2C); ok {
+		_, err = readI2C(device, reg, p)
+	} else {
+		_, err = bus.ReadReg(reg, p)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read register %d: %w", reg, err)
+	}
+	return nil
+}
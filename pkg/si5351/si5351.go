@@ -1,26 +1,66 @@
 package si5351
 
 import (
-	"errors"
+	"fmt"
 	"io"
+	"math/big"
+	"sync"
+	"time"
 )
 
 // DefaultI2CAddress is the default address of the Si5351 on the I2C bus.
 const DefaultI2CAddress uint8 = 0x60
 
 // Si5351 represents the chip.
+//
+// The Si5351 is safe for concurrent use by multiple goroutines. All methods of the Si5351, its PLLs, its outputs, and
+// its hop tables share one lock per device. Each method holds the lock for its complete sequence of register accesses,
+// so multi-register operations like SetupQuadratureOutput, Tune, or ApplyPlan are atomic. Methods that reset a PLL keep
+// the lock until the PLL is locked, if LockTimeout is set.
+// The exported fields are not guarded by the lock. Set up the configuration fields, like Crystal, LockTimeout, or
+// TuningStrategy, before the Si5351 is shared between goroutines, and use Inspect to read the state of the Si5351,
+// its PLLs, and its outputs while other goroutines may change it.
 type Si5351 struct {
-	Crystal      Crystal
-	InputDivider ClockDivider
+	Variant       Variant
+	Crystal       Crystal
+	Clkin         Clkin
+	InputDivider  ClockDivider
+	InterruptMask StatusBits
+	VCXOPullRange float64
+	Fanout        Fanout
+
+	// LockTimeout is the maximum time to wait for the PLLs to lock after they were reset.
+	// If LockTimeout is not zero, SetupPLL, SetupQuadratureOutput, and FinishSetup only return successfully
+	// once the PLLs are locked.
+	LockTimeout time.Duration
+
+	// AllowLowPLLFrequency allows SetupQuadratureOutput to run the PLL below 600MHz, down to 15 times the reference frequency.
+	// This extends the range of quadrature signals from 4.762MHz down to 2.976MHz with a 25MHz crystal. Those PLL frequencies
+	// are beyond the specification of the Si5351, but most devices lock reliably.
+	AllowLowPLLFrequency bool
+
+	// ExternalQuadratureDivider allows SetupQuadratureOutput to generate frequencies below the range of the phase offset
+	// register with the given external divider: the outputs carry a LO at a multiple of the frequency, see
+	// ExternalQuadratureDivider. With QuadratureBy4, this covers the full HF range from 1.8MHz upwards.
+	ExternalQuadratureDivider ExternalQuadratureDivider
+
+	// TuningStrategy decides how Tune divides the work between the PLL and the Multisynth of an output.
+	// If TuningStrategy is nil, Tune uses a FixedPLLStrategy.
+	TuningStrategy TuningStrategy
 
 	pll              []*PLL
 	fractionalOutput []*FractionalOutput
 	integerOutput    []*IntegerOutput
 
-	bus Bus
+	bus    Bus
+	shared *sharedRegisters
+	mutex  *sync.Mutex
 }
 
-// Bus on which to communicate with the Si5351.
+// Bus on which to communicate with the Si5351. The Si5351 serializes its access to the bus, the bus must not be used
+// by others at the same time.
+// Each method of the Si5351 returns the errors of its own register accesses, the bus should not fail later accesses
+// because of an earlier error. *i2c.I2C keeps the first error and fails all further accesses, use OpenI2C instead.
 type Bus interface {
 	ReadReg(reg uint8, p []byte) (int, error)
 	WriteReg(reg uint8, values ...byte) (int, error)
@@ -29,37 +69,110 @@ type Bus interface {
 	Close() error
 }
 
-// New returns a new Si5351 instance.
-func New(crystal Crystal, bus Bus) *Si5351 {
-	return &Si5351{
+// New returns a new Si5351 instance for the given variant.
+// The state of the PLLs and outputs is unknown until Load or StartSetup is called. Registers that are shared between
+// several PLLs or outputs are read from the device before they are changed for the first time, this also updates
+// the state of the PLLs and outputs that use them.
+func New(variant Variant, crystal Crystal, bus Bus) *Si5351 {
+	shared := newSharedRegisters()
+	mutex := new(sync.Mutex)
+	result := &Si5351{
+		Variant:          variant,
 		Crystal:          crystal,
-		pll:              loadPLLs(bus),
-		fractionalOutput: loadFractionalOutputs(bus),
-		integerOutput:    loadIntegerOutputs(bus),
+		pll:              loadPLLs(bus, shared, mutex),
+		fractionalOutput: loadFractionalOutputs(bus, shared, mutex),
+		integerOutput:    loadIntegerOutputs(bus, shared, mutex),
 		bus:              bus,
+		shared:           shared,
+		mutex:            mutex,
 	}
+	result.forEachOutput(func(o *Output) {
+		o.unsupported = variant.checkOutput(o.index())
+	})
+	shared.loaded = result.loadShared
+	return result
+}
+
+// Load reads the current configuration from the Si5351's registers into the PLLs and outputs.
+// Use Load to attach to a device that is already running without initializing it again.
+// PLLs and Multisynths with invalid parameters in their registers, e.g. unused ones after power-up, are loaded with
+// the zero ratio, see FractionalRatio.IsZero.
+func (s *Si5351) Load() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var registers [256]byte
+	for _, block := range registerBlocks {
+		if err := readRegisters(s.bus, block.first, registers[block.first:block.last+1]); err != nil {
+			return err
+		}
+	}
+
+	s.shared.load(registers[:])
+	s.Crystal.Load = CrystalLoad(registers[RegCrystalInternalLoadCapacitance] & 0xC0)
+	s.InputDivider = ClockDivider((registers[RegPLLInputSource] >> 6) & 3)
+	s.InterruptMask = StatusBits(registers[RegInterruptStatusMask]) & AllStatusBits
+	s.Fanout = decodeFanout(registers[RegFanoutEnable])
+	for _, p := range s.pll {
+		if err := p.load(registers[:]); err != nil {
+			return err
+		}
+	}
+	for _, o := range s.fractionalOutput {
+		if err := o.load(registers[:]); err != nil {
+			return err
+		}
+	}
+	for _, o := range s.integerOutput {
+		o.load(registers[:])
+	}
+	return nil
 }
 
 // StartSetup starts the setup sequence of the Si5351:
 // * disable all outputs
 // * power down all output drivers
-// * set the CLKIN input divider
+// * set the CLKIN input divider and the input sources of the PLLs
 // * set the internal load capacitance of the crystal
 // After these steps the individual setup of PLLs and Clocks should take place.
 // As last setup step, don't forget to call FinishSetup.
 func (s *Si5351) StartSetup() error {
-	s.Shutdown()
-	s.bus.WriteReg(RegCrystalInternalLoadCapacitance, byte(s.Crystal.Load))
-	return s.bus.Err()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.shutdown(); err != nil {
+		return err
+	}
+	if err := s.setupPLLInputSource(s.InputDivider, s.PLLA().InputSource, s.PLLB().InputSource); err != nil {
+		return err
+	}
+	return writeRegisters(s.bus, RegCrystalInternalLoadCapacitance, byte(s.Crystal.Load))
 }
 
 // FinishSetup finishes the setup sequence:
 // * reset the PLLs
+// * wait for the PLLs that are used by powered up outputs to lock, if LockTimeout is set
 // * enable all outputs
 func (s *Si5351) FinishSetup() error {
-	s.resetAllPLLs()
-	s.enableAllOutputs(true)
-	return s.bus.Err()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.resetAllPLLs(); err != nil {
+		return err
+	}
+	if err := s.awaitLock(s.usedPLLs()...); err != nil {
+		return err
+	}
+	return s.enableAllOutputs(true)
+}
+
+// Inspect calls the given function while holding the lock of the Si5351. Use it to read a consistent state of the
+// Si5351, its PLLs, and its outputs while other goroutines may change it. The function must not call any methods
+// of the Si5351, its PLLs, its outputs, or its hop tables, this would deadlock.
+func (s *Si5351) Inspect(f func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	f()
 }
 
 // PLL returns the PLL with the given index.
@@ -82,7 +195,17 @@ func (s *Si5351) Output(output OutputIndex) *Output {
 	if output <= Clk5 {
 		return &s.fractionalOutput[output].Output
 	}
-	return &s.integerOutput[output].Output
+	return &s.integerOutput[output-Clk6].Output
+}
+
+// FractionalOutput returns the output with the given index, which must be one of CLK0-CLK5.
+func (s *Si5351) FractionalOutput(output OutputIndex) *FractionalOutput {
+	return s.fractionalOutput[output]
+}
+
+// IntegerOutput returns the output with the given index, which must be CLK6 or CLK7.
+func (s *Si5351) IntegerOutput(output OutputIndex) *IntegerOutput {
+	return s.integerOutput[output-Clk6]
 }
 
 // Clk0 returns the output CLK0.
@@ -126,155 +249,535 @@ func (s *Si5351) Clk7() *IntegerOutput {
 }
 
 // SetupPLLInputSource writes the input source configuration to the Si5351's register.
+// The CLKIN input divider must be one of ClockBy1, ClockBy2, ClockBy4, or ClockBy8.
 func (s *Si5351) SetupPLLInputSource(clkinInputDivider ClockDivider, pllASource, pllBSource PLLInputSource) error {
-	value := byte((clkinInputDivider&0xF)<<4) |
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.setupPLLInputSource(clkinInputDivider, pllASource, pllBSource)
+}
+
+func (s *Si5351) setupPLLInputSource(clkinInputDivider ClockDivider, pllASource, pllBSource PLLInputSource) error {
+	if pllASource == PLLInputClkin || pllBSource == PLLInputClkin {
+		if err := s.Variant.checkInput(InputClkin); err != nil {
+			return err
+		}
+	}
+	if clkinInputDivider > ClockBy8 {
+		return fmt.Errorf("invalid CLKIN input divider %d, must be within 1-8", clkinInputDivider.Factor())
+	}
+	value := byte((clkinInputDivider&0x3)<<6) |
 		byte((pllASource&1)<<s.PLLA().Register.InputSourceOffset) |
 		byte((pllBSource&1)<<s.PLLB().Register.InputSourceOffset)
 
-	s.bus.WriteReg(RegPLLInputSource, value)
+	if err := writeRegisters(s.bus, RegPLLInputSource, value); err != nil {
+		return err
+	}
+
+	s.InputDivider = clkinInputDivider
+	s.PLLA().InputSource = pllASource
+	s.PLLB().InputSource = pllBSource
+	return nil
+}
+
+// SetupClkin selects the reference clock on the CLKIN input as input source for the given PLLs. The CLKIN input divider is
+// chosen automatically to bring the CLKIN frequency into the range of the PLL's reference frequency (10-40MHz).
+// The other PLLs keep their input source.
+func (s *Si5351) SetupClkin(clkin Clkin, plls ...PLLIndex) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Variant.checkInput(InputClkin); err != nil {
+		return err
+	}
+	inputDivider, err := FindClkinInputDivider(clkin.Frequency())
+	if err != nil {
+		return err
+	}
+
+	sources := []PLLInputSource{s.PLLA().InputSource, s.PLLB().InputSource}
+	for _, pll := range plls {
+		sources[pll] = PLLInputClkin
+	}
+	if err := s.setupPLLInputSource(inputDivider, sources[PLLA], sources[PLLB]); err != nil {
+		return err
+	}
+	s.Clkin = clkin
+	return nil
+}
+
+// ReferenceFrequency returns the reference frequency of the given PLL, depending on its input source.
+// For CLKIN, this is the frequency after the CLKIN input divider.
+func (s *Si5351) ReferenceFrequency(pll PLLIndex) Frequency {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.referenceFrequency(pll)
+}
 
-	if s.bus.Err() == nil {
-		s.InputDivider = clkinInputDivider
-		s.PLLA().InputSource = pllASource
-		s.PLLB().InputSource = pllBSource
+func (s *Si5351) referenceFrequency(pll PLLIndex) Frequency {
+	if s.pll[pll].InputSource == PLLInputClkin {
+		return s.Clkin.Frequency() / Frequency(s.InputDivider.Factor())
 	}
-	return s.bus.Err()
+	return s.Crystal.Frequency()
+}
+
+// ExactReferenceFrequency returns the exact reference frequency of the given PLL, depending on its input source.
+func (s *Si5351) ExactReferenceFrequency(pll PLLIndex) *big.Rat {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.exactReferenceFrequency(pll)
+}
+
+func (s *Si5351) exactReferenceFrequency(pll PLLIndex) *big.Rat {
+	if s.pll[pll].InputSource == PLLInputClkin {
+		result := s.Clkin.ExactFrequency()
+		return result.Quo(result, new(big.Rat).SetInt64(int64(s.InputDivider.Factor())))
+	}
+	return s.Crystal.ExactFrequency()
+}
+
+// exactPLLFrequency returns the exact frequency of the given PLL, based on its current multiplier.
+func (s *Si5351) exactPLLFrequency(pll PLLIndex) *big.Rat {
+	return s.pll[pll].Multiplier.MultiplyExact(s.exactReferenceFrequency(pll))
 }
 
 // SetupPLLRaw directly sets the frequency multiplier parameters for the given PLL and resets it.
 func (s *Si5351) SetupPLLRaw(pll PLLIndex, a, b, c uint32) error {
-	s.pll[pll].SetupMultiplier(FractionalRatio{A: a, B: b, C: c})
-	s.pll[pll].Reset()
-	return s.bus.Err()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.pll[pll].setupMultiplier(FractionalRatio{A: a, B: b, C: c}); err != nil {
+		return err
+	}
+	return s.pll[pll].reset()
 }
 
 // SetupMultisynthRaw directly sets the frequency divider and RDiv parameters for the Multisynth of the given output.
+// For CLK6 and CLK7, a must be an even integer between 6 and 254, b and c are ignored.
 func (s *Si5351) SetupMultisynthRaw(output OutputIndex, a, b, c uint32, RDiv ClockDivider) error {
-	if int(output) >= len(s.fractionalOutput) {
-		return errors.New("only CLK0-CLK5 are currently supported")
-	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	s.fractionalOutput[output].SetupDivider(FractionalRatio{A: a, B: b, C: c})
+	if err := s.Variant.checkOutput(output); err != nil {
+		return err
+	}
+	if output >= Clk6 {
+		if a > MaxIntegerDivider {
+			return fmt.Errorf("invalid integer divider %d, must be even and within %d-%d", a, MinIntegerDivider, MaxIntegerDivider)
+		}
+		return s.integerOutput[output-Clk6].setupDivider(uint8(a), RDiv)
+	}
 
-	return s.bus.Err()
+	return s.fractionalOutput[output].setupDivider(FractionalRatio{A: a, B: b, C: c, ClockDivider: RDiv})
 }
 
 // SetupPLL sets the given PLL to the closest possible value of the given frequency and resets it.
-func (s *Si5351) SetupPLL(pll PLLIndex, frequency Frequency) (Frequency, error) {
-	multiplier := FindFractionalMultiplier(s.Crystal.Frequency(), frequency)
+// The frequency must be within 600MHz and 900MHz, otherwise SetupPLL returns a *RangeError that wraps ErrPLLOutOfRange.
+// The method returns the exact effective PLL frequency.
+func (s *Si5351) SetupPLL(pll PLLIndex, frequency Frequency) (Tuning, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := checkPLLFrequency(frequency); err != nil {
+		return Tuning{}, err
+	}
+	multiplier, err := FindFractionalMultiplier(s.referenceFrequency(pll), frequency)
+	if err != nil {
+		return Tuning{}, err
+	}
 
-	s.pll[pll].SetupMultiplier(multiplier)
-	s.pll[pll].Reset()
+	if err := s.pll[pll].setupMultiplier(multiplier); err != nil {
+		return Tuning{}, err
+	}
+	if err := s.pll[pll].reset(); err != nil {
+		return Tuning{}, err
+	}
 
-	return multiplier.Multiply(s.Crystal.Frequency()), s.bus.Err()
+	return Tuning{Requested: frequency, Achieved: s.exactPLLFrequency(pll)}, s.awaitLock(pll)
 }
 
-// PrepareOutputs prepares the given outputs for use with the given PLL and control parameters.
-func (s *Si5351) PrepareOutputs(pll PLLIndex, invert bool, inputSource ClockInputSource, drive OutputDrive, outputs ...OutputIndex) error {
+// PrepareOutputs prepares the given outputs for use with the given PLL, control parameters, and disable state.
+// If the outputs use the crystal, CLKIN or the shared Multisynth as input source, the corresponding fanout is enabled.
+// The integer mode of the outputs is kept, it follows the dividers of the outputs.
+func (s *Si5351) PrepareOutputs(pll PLLIndex, invert bool, inputSource ClockInputSource, drive OutputDrive, disableState OutputDisableState, outputs ...OutputIndex) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.checkOutputs(outputs...); err != nil {
+		return err
+	}
+	if err := s.enableFanoutFor(inputSource); err != nil {
+		return err
+	}
 	for _, output := range outputs {
-		s.Output(output).SetupControl(false, false, pll, invert, inputSource, drive)
+		o := s.Output(output)
+		if err := o.setupControl(false, o.IntegerMode, pll, invert, inputSource, drive); err != nil {
+			return err
+		}
+		if err := o.setDisableState(disableState); err != nil {
+			return err
+		}
 	}
-	return s.bus.Err()
+	return nil
 }
 
 // SetOutputFrequency sets the given output to the closest possible value of the given frequency that can be
 // generated with the PLL the output is associated with. Set the frequency of the PLL first.
-// The method returns the effective output frequency.
-func (s *Si5351) SetOutputFrequency(output OutputIndex, frequency Frequency) (Frequency, error) {
-	if int(output) >= len(s.fractionalOutput) {
-		return 0, errors.New("only CLK0-CLK5 are currently supported")
+// For low frequencies, the R divider of the output is selected automatically. Frequencies above 150MHz are generated
+// using the divide-by-4 mode, this also sets the PLL of the output to four times the given frequency.
+// The frequency must be within 2.5kHz and 200MHz, otherwise SetOutputFrequency returns a *RangeError that wraps
+// ErrOutputOutOfRange.
+// The method returns the exact effective output frequency.
+func (s *Si5351) SetOutputFrequency(output OutputIndex, frequency Frequency) (Tuning, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Variant.checkOutput(output); err != nil {
+		return Tuning{}, err
+	}
+	if err := checkOutputFrequency(frequency); err != nil {
+		return Tuning{}, err
+	}
+	if output >= Clk6 {
+		o := s.integerOutput[output-Clk6]
+		pllFrequency := s.pll[o.PLL].Multiplier.Multiply(s.referenceFrequency(o.PLL))
+		divider, rDiv, err := FindIntegerDivider(pllFrequency, frequency)
+		if err != nil {
+			return Tuning{}, err
+		}
+		if err := o.setupDivider(divider, rDiv); err != nil {
+			return Tuning{}, err
+		}
+		return Tuning{Requested: frequency, Achieved: o.divideExact(s.exactPLLFrequency(o.PLL))}, nil
 	}
 
 	o := s.fractionalOutput[output]
-	pllFrequency := s.pll[o.PLL].Multiplier.Multiply(s.Crystal.Frequency())
-	divider := FindFractionalDivider(pllFrequency, frequency)
-	o.SetupDivider(divider)
+	if frequency > MinBy4Frequency {
+		return s.setOutputFrequencyBy4(o, frequency)
+	}
 
-	return divider.Divide(pllFrequency), s.bus.Err()
+	pllFrequency := s.pll[o.PLL].Multiplier.Multiply(s.referenceFrequency(o.PLL))
+	divider, err := FindFractionalDivider(pllFrequency, frequency)
+	if err != nil {
+		return Tuning{}, err
+	}
+	if err := o.setupDivider(divider); err != nil {
+		return Tuning{}, err
+	}
+
+	return Tuning{Requested: frequency, Achieved: divider.DivideExact(s.exactPLLFrequency(o.PLL))}, nil
+}
+
+// setOutputFrequencyBy4 uses the divide-by-4 mode of the Multisynth to generate frequencies above 150MHz.
+// The PLL of the output is set to four times the output frequency and reset.
+func (s *Si5351) setOutputFrequencyBy4(o *FractionalOutput, frequency Frequency) (Tuning, error) {
+	p := s.pll[o.PLL]
+	multiplier, divider, err := FindFractionalMultiplierWithBy4Divider(s.referenceFrequency(o.PLL), frequency)
+	if err != nil {
+		return Tuning{}, err
+	}
+
+	if err := p.setupMultiplier(multiplier); err != nil {
+		return Tuning{}, err
+	}
+	if err := o.setupDivider(divider); err != nil {
+		return Tuning{}, err
+	}
+	if err := p.reset(); err != nil {
+		return Tuning{}, err
+	}
+
+	return Tuning{Requested: frequency, Achieved: divider.DivideExact(s.exactPLLFrequency(o.PLL))}, s.awaitLock(o.PLL)
 }
 
 // SetOutputDivider sets the divider of the given output.
-// The method returns the effective output frequency.
-func (s *Si5351) SetOutputDivider(output OutputIndex, a, b, c uint32) (Frequency, error) {
-	if int(output) >= len(s.fractionalOutput) {
-		return 0, errors.New("only CLK0-CLK5 are currently supported")
+// For CLK6 and CLK7, the divider must be an even integer between 6 and 254 (b = 0), the R divider is kept.
+// The method returns the exact effective output frequency.
+func (s *Si5351) SetOutputDivider(output OutputIndex, a, b, c uint32) (Tuning, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Variant.checkOutput(output); err != nil {
+		return Tuning{}, err
+	}
+	if output >= Clk6 {
+		if b != 0 || a > MaxIntegerDivider {
+			return Tuning{}, fmt.Errorf("invalid divider %d %d/%d for CLK%d, only even integer dividers within %d-%d are supported", a, b, c, output, MinIntegerDivider, MaxIntegerDivider)
+		}
+		o := s.integerOutput[output-Clk6]
+		if err := o.setupDivider(uint8(a), o.RDiv); err != nil {
+			return Tuning{}, err
+		}
+		return Tuning{Achieved: o.divideExact(s.exactPLLFrequency(o.PLL))}, nil
 	}
 
 	o := s.fractionalOutput[output]
-	pllFrequency := s.pll[o.PLL].Multiplier.Multiply(s.Crystal.Frequency())
 	divider := FractionalRatio{A: a, B: b, C: c}
-	o.SetupDivider(divider)
+	if err := o.setupDivider(divider); err != nil {
+		return Tuning{}, err
+	}
 
-	return divider.Divide(pllFrequency), s.bus.Err()
+	return Tuning{Achieved: divider.DivideExact(s.exactPLLFrequency(o.PLL))}, nil
 }
 
 // SetupQuadratureOutput sets up the given PLL and the given outputs to generate the closest possible value
 // of the given frequency with a quadrature signal (90° phase shifted) on the second output.
-// The method returns the effective PLL frequency and the effective output frequency.
-func (s *Si5351) SetupQuadratureOutput(pll PLLIndex, phase, quadrature OutputIndex, frequency Frequency) (Frequency, Frequency, error) {
+// With a PLL frequency of 600-900MHz, quadrature signals are available from 4.762MHz up to 200MHz, AllowLowPLLFrequency
+// extends the range downwards. Frequencies out of reach are rejected with a *QuadratureRangeError, see FindQuadratureRatios.
+// If ExternalQuadratureDivider is set, frequencies below the range are generated as LO for the external divider
+// instead: the phase output carries the LO, the quadrature output carries the inverted LO, see
+// FindExternalQuadratureRatios. The output tuning then reports the ratio of the external divider.
+// The method returns the exact effective PLL frequency and the exact effective output frequency.
+func (s *Si5351) SetupQuadratureOutput(pll PLLIndex, phase, quadrature OutputIndex, frequency Frequency) (Tuning, Tuning, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.checkOutputs(phase, quadrature); err != nil {
+		return Tuning{}, Tuning{}, err
+	}
 	if int(phase) >= len(s.fractionalOutput) || int(quadrature) >= len(s.fractionalOutput) {
-		return 0, 0, errors.New("only CLK0-CLK5 are currently supported")
+		return Tuning{}, Tuning{}, fmt.Errorf("only CLK0-CLK5 support a phase shift: %w", ErrUnsupportedOutput)
+	}
+
+	refFrequency := s.referenceFrequency(pll)
+	minPLLFrequency := s.quadratureMinPLLFrequency(pll)
+	external := s.ExternalQuadratureDivider
+	var multiplier, divider FractionalRatio
+	var err error
+	if external != NoExternalQuadratureDivider && frequency < MinQuadratureFrequency(minPLLFrequency) {
+		multiplier, divider, err = FindExternalQuadratureRatios(refFrequency, frequency, minPLLFrequency, external)
+	} else {
+		external = NoExternalQuadratureDivider
+		multiplier, divider, err = FindQuadratureRatios(refFrequency, frequency, minPLLFrequency)
+	}
+	if err != nil {
+		return Tuning{}, Tuning{}, err
 	}
 
 	p := s.pll[pll]
 	i := s.fractionalOutput[phase]
 	q := s.fractionalOutput[quadrature]
 
-	// Find the multiplier and an integer divider.
-	multiplier, divider := FindFractionalMultiplierWithIntegerDivider(s.Crystal.Frequency(), frequency)
-	pllFrequency := multiplier.Multiply(s.Crystal.Frequency())
-	outputFrequency := divider.Divide(pllFrequency)
-
-	i.SetPLL(pll)
-	q.SetPLL(pll)
-	p.SetupMultiplier(multiplier)
-	i.SetupDivider(divider)
-	q.SetupDivider(divider)
+	if err := i.setPLL(pll); err != nil {
+		return Tuning{}, Tuning{}, err
+	}
+	if err := q.setPLL(pll); err != nil {
+		return Tuning{}, Tuning{}, err
+	}
+	if err := p.setupMultiplier(multiplier); err != nil {
+		return Tuning{}, Tuning{}, err
+	}
+	if err := i.setupDivider(divider); err != nil {
+		return Tuning{}, Tuning{}, err
+	}
+	if err := q.setupDivider(divider); err != nil {
+		return Tuning{}, Tuning{}, err
+	}
 
-	shift := uint8(divider.A & 0xFF)
-	i.SetupPhaseShift(0)
-	q.SetupPhaseShift(shift)
+	// 90° are as many phase offset steps as the divider, the external divider shifts the phase with the inverted LO
+	shift, invert := uint8(divider.A), false
+	if external != NoExternalQuadratureDivider {
+		shift, invert = 0, true
+	}
+	if err := i.setupPhaseShift(0); err != nil {
+		return Tuning{}, Tuning{}, err
+	}
+	if err := q.setupPhaseShift(shift); err != nil {
+		return Tuning{}, Tuning{}, err
+	}
+	if err := i.setInvert(false); err != nil {
+		return Tuning{}, Tuning{}, err
+	}
+	if err := q.setInvert(invert); err != nil {
+		return Tuning{}, Tuning{}, err
+	}
 
-	p.Reset()
+	if err := p.reset(); err != nil {
+		return Tuning{}, Tuning{}, err
+	}
 
-	return pllFrequency, outputFrequency, s.bus.Err()
+	factor := Frequency(1)
+	if external != NoExternalQuadratureDivider {
+		factor = Frequency(external)
+	}
+	pllFrequency := s.exactPLLFrequency(pll)
+	pllTuning := Tuning{Requested: frequency * factor * Frequency(divider.A) * Frequency(divider.ClockDivider.Factor()), Achieved: pllFrequency}
+	achieved := divider.DivideExact(pllFrequency)
+	achieved.Quo(achieved, factor.Rat())
+	outputTuning := Tuning{Requested: frequency, Achieved: achieved, ExternalDivider: uint32(external)}
+	return pllTuning, outputTuning, s.awaitLock(pll)
 }
 
 // Shutdown the Si5351: disable all outputs, power down all output drivers.
 func (s *Si5351) Shutdown() error {
-	s.enableAllOutputs(false)
-	s.powerDownAllOutputDrivers()
-	return s.bus.Err()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.shutdown()
+}
+
+func (s *Si5351) shutdown() error {
+	if err := s.enableAllOutputs(false); err != nil {
+		return err
+	}
+	return s.powerDownAllOutputDrivers()
+}
+
+// EnableOutputs enables or disables the given outputs with one write to the output enable control register.
+// All other outputs keep their state.
+func (s *Si5351) EnableOutputs(enabled bool, outputs ...OutputIndex) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.checkOutputs(outputs...); err != nil {
+		return err
+	}
+	var mask byte
+	for _, output := range outputs {
+		mask |= 1 << uint(output)
+	}
+	return s.enableOutputs(enabled, mask)
+}
+
+// SetOEBControl defines if the given outputs are enabled and disabled by the OEB pin with one write to the
+// OEB pin enable control register. All other outputs keep their setting.
+func (s *Si5351) SetOEBControl(controlled bool, outputs ...OutputIndex) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.checkOutputs(outputs...); err != nil {
+		return err
+	}
+	var mask byte
+	for _, output := range outputs {
+		mask |= 1 << uint(output)
+	}
+
+	var value byte
+	if !controlled {
+		value = mask
+	}
+	err := s.shared.modify(s.bus, RegOebPinEnableControl, mask, value)
+	if err == nil {
+		s.forEachOutput(func(o *Output) {
+			if mask&(1<<uint(o.index())) != 0 {
+				o.OEBControlled = controlled
+			}
+		})
+	}
+	return err
 }
 
 func (s *Si5351) enableAllOutputs(enabled bool) error {
+	return s.enableOutputs(enabled, 0xFF)
+}
+
+func (s *Si5351) enableOutputs(enabled bool, mask byte) error {
 	var value byte
-	if enabled {
-		value = 0x00
-	} else {
-		value = 0xFF
+	if !enabled {
+		value = mask
+	}
+	err := s.shared.modify(s.bus, RegOutputEnableControl, mask, value)
+	if err == nil {
+		s.forEachOutput(func(o *Output) {
+			if mask&(1<<uint(o.index())) != 0 {
+				o.Enabled = enabled
+			}
+		})
 	}
-	_, err := s.bus.WriteReg(RegOutputEnableControl, value)
 	return err
 }
 
 func (s *Si5351) powerDownAllOutputDrivers() error {
 	// for all clocks: power down, fractional division mode, PLLA, not inverted, Multisynth, 2mA
-	_, err := s.bus.WriteReg(RegClk0Control,
-		0x80,
-		0x80,
+	// CLK6 and CLK7 keep the integer mode bits of the PLLs
+	clk6, err := s.shared.get(s.bus, RegClk6Control)
+	if err != nil {
+		return err
+	}
+	clk7, err := s.shared.get(s.bus, RegClk7Control)
+	if err != nil {
+		return err
+	}
+	clk6 = 0x80 | clk6&(1<<6)
+	clk7 = 0x80 | clk7&(1<<6)
+	err = writeRegisters(s.bus, RegClk0Control,
 		0x80,
 		0x80,
 		0x80,
 		0x80,
 		0x80,
 		0x80,
+		clk6,
+		clk7,
 	)
+	if err == nil {
+		s.shared.set(RegClk6Control, clk6)
+		s.shared.set(RegClk7Control, clk7)
+		s.forEachOutput(func(o *Output) {
+			o.PowerDown = true
+			o.IntegerMode = false
+			o.PLL = PLLA
+			o.Invert = false
+			o.InputSource = ClockInputCrystal
+			o.Drive = OutputDrive2mA
+		})
+	}
 	return err
 }
 
+func (s *Si5351) checkOutputs(outputs ...OutputIndex) error {
+	for _, output := range outputs {
+		if err := s.Variant.checkOutput(output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// usedPLLs returns the PLLs that are used by powered up outputs.
+func (s *Si5351) usedPLLs() []PLLIndex {
+	used := make([]bool, len(s.pll))
+	s.forEachOutput(func(o *Output) {
+		if !o.PowerDown && o.InputSource == ClockInputMultisynth {
+			used[o.PLL] = true
+		}
+	})
+
+	result := make([]PLLIndex, 0, len(s.pll))
+	for i, u := range used {
+		if u {
+			result = append(result, PLLIndex(i))
+		}
+	}
+	return result
+}
+
+func (s *Si5351) forEachOutput(f func(*Output)) {
+	for _, o := range s.fractionalOutput {
+		f(&o.Output)
+	}
+	for _, o := range s.integerOutput {
+		f(&o.Output)
+	}
+}
+
+// loadShared updates the state of the PLLs, outputs, and fanouts with the value of a shared register that was read
+// from the device.
+func (s *Si5351) loadShared(reg uint8, value byte) {
+	if reg == RegFanoutEnable {
+		s.Fanout = decodeFanout(value)
+	}
+	for _, p := range s.pll {
+		p.loadShared(reg, value)
+	}
+	for _, o := range s.fractionalOutput {
+		o.loadShared(reg, value)
+	}
+	for _, o := range s.integerOutput {
+		o.loadShared(reg, value)
+	}
+}
+
 func (s *Si5351) resetAllPLLs() error {
 	value := byte((1 << 7) | (1 << 5))
-	s.bus.WriteReg(RegPLLReset, value)
-	return s.bus.Err()
+	return writeRegisters(s.bus, RegPLLReset, value)
 }
@@ -0,0 +1,448 @@
+package si5351
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeBus struct {
+	registers [256]byte
+	onRead    func(reg uint8)
+	onWrite   func(reg uint8, values []byte)
+	writeErr  error
+}
+
+func (b *fakeBus) ReadReg(reg uint8, p []byte) (int, error) {
+	if b.onRead != nil {
+		b.onRead(reg)
+	}
+	return copy(p, b.registers[reg:]), nil
+}
+
+func (b *fakeBus) WriteReg(reg uint8, values ...byte) (int, error) {
+	if b.onWrite != nil {
+		b.onWrite(reg, values)
+	}
+	if b.writeErr != nil {
+		return 0, b.writeErr
+	}
+	return copy(b.registers[reg:], values), nil
+}
+
+func (b *fakeBus) RegWriter(reg uint8) io.Writer {
+	return &fakeRegWriter{reg: reg, bus: b}
+}
+
+func (b *fakeBus) Err() error {
+	return nil
+}
+
+func (b *fakeBus) Close() error {
+	return nil
+}
+
+type fakeRegWriter struct {
+	reg uint8
+	bus *fakeBus
+}
+
+func (w *fakeRegWriter) Write(p []byte) (int, error) {
+	return w.bus.WriteReg(w.reg, p...)
+}
+
+func TestLoad(t *testing.T) {
+	crystal := Crystal{BaseFrequency: Crystal25MHz, Load: CrystalLoad10PF}
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, crystal, bus)
+	device.StartSetup()
+	device.SetupPLL(PLLB, 800*MHz)
+	device.PrepareOutputs(PLLB, true, ClockInputMultisynth, OutputDrive6mA, OutputDisableHighZ, Clk2)
+	device.SetOutputFrequency(Clk2, 7*MHz)
+	device.Clk2().SetupPhaseShift(42)
+	device.Clk7().SetupControl(false, false, PLLB, false, ClockInputMultisynth, OutputDrive4mA)
+	device.Clk7().SetupDivider(100, ClockBy8)
+	device.FinishSetup()
+
+	loaded := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	err := loaded.Load()
+
+	assert.NoError(t, err)
+	assert.Equal(t, CrystalLoad10PF, loaded.Crystal.Load)
+	assert.Equal(t, device.PLLB().Multiplier, loaded.PLLB().Multiplier)
+	expectedOutput, actualOutput := device.Clk2().Output, loaded.Clk2().Output
+	expectedOutput.shared, actualOutput.shared = nil, nil
+	assert.Equal(t, expectedOutput, actualOutput)
+	assert.Equal(t, device.Clk2().FrequencyDivider, loaded.Clk2().FrequencyDivider)
+	assert.Equal(t, uint8(42), loaded.Clk2().PhaseShift)
+	assert.True(t, loaded.Clk2().Enabled)
+	assert.Equal(t, PLLB, loaded.Clk7().PLL)
+	assert.Equal(t, OutputDrive4mA, loaded.Clk7().Drive)
+	assert.Equal(t, uint8(100), loaded.Clk7().FrequencyDivider)
+	assert.Equal(t, ClockBy8, loaded.Clk7().RDiv)
+}
+
+func TestIntegerOutputs(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.SetupPLL(PLLA, 900*MHz)
+	device.PrepareOutputs(PLLA, false, ClockInputMultisynth, OutputDrive2mA, OutputDisableLow, Clk6, Clk7)
+
+	f6, err := device.SetOutputFrequency(Clk6, 10*MHz)
+	assert.NoError(t, err)
+	assert.Equal(t, 10*MHz, f6.Frequency())
+	assert.Equal(t, uint8(90), device.Clk6().FrequencyDivider)
+	assert.Equal(t, ClockBy1, device.Clk6().RDiv)
+
+	f7, err := device.SetOutputFrequency(Clk7, 93750*Hz)
+	assert.NoError(t, err)
+	assert.Equal(t, 93750*Hz, f7.Frequency())
+	assert.Equal(t, uint8(150), device.Clk7().FrequencyDivider)
+	assert.Equal(t, ClockBy64, device.Clk7().RDiv)
+
+	assert.Equal(t, byte(90), bus.registers[RegMultisynth6Parameters])
+	assert.Equal(t, device.Clk7().FrequencyDivider, bus.registers[RegMultisynth7Parameters])
+	assert.Equal(t, byte(device.Clk7().RDiv)<<4, bus.registers[RegClock6_7OutputDivider])
+	assert.Equal(t, &device.Clk7().Output, device.Output(Clk7))
+}
+
+func TestIntegerOutputInvalidDivider(t *testing.T) {
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, new(fakeBus))
+
+	assert.Error(t, device.Clk6().SetupDivider(7, ClockBy1))
+	assert.Error(t, device.Clk6().SetupDivider(4, ClockBy1))
+	_, err := device.SetOutputDivider(Clk7, 10, 1, 2)
+	assert.Error(t, err)
+}
+
+func TestSetupMultisynthRawWithRDiv(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+
+	err := device.SetupMultisynthRaw(Clk1, 900, 0, 1, ClockBy32)
+
+	assert.NoError(t, err)
+	assert.Equal(t, ClockBy32, device.Clk1().FrequencyDivider.ClockDivider)
+	assert.Equal(t, byte(ClockBy32)<<4, bus.registers[RegMultisynth1Parameters+2]&0x70)
+}
+
+func TestSetOutputFrequencyBy4(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.SetupPLL(PLLA, 900*MHz)
+	device.PrepareOutputs(PLLA, false, ClockInputMultisynth, OutputDrive2mA, OutputDisableLow, Clk0)
+
+	f, err := device.SetOutputFrequency(Clk0, 180*MHz)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 180*MHz, f.Frequency())
+	assert.Equal(t, 720*MHz, device.PLLA().Multiplier.Multiply(device.Crystal.Frequency()))
+	assert.True(t, device.Clk0().FrequencyDivider.By4)
+	assert.True(t, device.Clk0().IntegerMode)
+	assert.Equal(t, byte(0x0C), bus.registers[RegMultisynth0Parameters+2]&0x0C)
+	assert.Equal(t, byte(1<<6), bus.registers[RegClk0Control]&(1<<6))
+
+	_, err = device.SetOutputFrequency(Clk0, 7*MHz)
+
+	assert.NoError(t, err)
+	assert.False(t, device.Clk0().FrequencyDivider.By4)
+	assert.False(t, device.Clk0().IntegerMode)
+}
+
+func TestEnableOutputs(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	bus.registers[RegOutputEnableControl] = 0xF0
+	device.Load()
+
+	err := device.Clk1().Enable(false)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0xF2), bus.registers[RegOutputEnableControl])
+	assert.False(t, device.Clk1().Enabled)
+
+	err = device.EnableOutputs(true, Clk1, Clk4, Clk7)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x60), bus.registers[RegOutputEnableControl])
+	assert.True(t, device.Clk1().Enabled)
+	assert.True(t, device.Clk4().Enabled)
+	assert.False(t, device.Clk5().Enabled)
+	assert.True(t, device.Clk7().Enabled)
+
+	err = device.Output(Clk6).Enable(true)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x20), bus.registers[RegOutputEnableControl])
+}
+
+func TestEnableOutputWithoutLoad(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	bus.registers[RegOutputEnableControl] = 0xF0
+
+	err := device.Clk0().Enable(true)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0xF0), bus.registers[RegOutputEnableControl])
+	assert.True(t, device.Clk0().Enabled)
+	assert.True(t, device.Clk3().Enabled)
+	assert.False(t, device.Clk4().Enabled)
+
+	bus.registers[RegOutputEnableControl] = 0xFF
+	device = New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+
+	err = device.Clk0().Enable(true)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0xFE), bus.registers[RegOutputEnableControl])
+	assert.False(t, device.Clk1().Enabled)
+}
+
+func TestSetDisableState(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+
+	assert.NoError(t, device.Clk1().SetDisableState(OutputDisableHighZ))
+	assert.NoError(t, device.Clk3().SetDisableState(OutputDisableNever))
+	assert.NoError(t, device.Clk7().SetDisableState(OutputDisableHigh))
+	assert.NoError(t, device.Clk3().SetDisableState(OutputDisableLow))
+
+	assert.Equal(t, byte(0x08), bus.registers[RegClk3_0DisableState])
+	assert.Equal(t, byte(0x40), bus.registers[RegClk7_4DisableState])
+	assert.Equal(t, OutputDisableHighZ, device.Clk1().DisableState)
+	assert.Equal(t, OutputDisableLow, device.Clk3().DisableState)
+	assert.Equal(t, OutputDisableHigh, device.Clk7().DisableState)
+}
+
+func TestSetDisableStateWithoutLoad(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	bus.registers[RegClk3_0DisableState] = 0xFF
+
+	assert.NoError(t, device.Clk1().SetDisableState(OutputDisableLow))
+
+	assert.Equal(t, byte(0xF3), bus.registers[RegClk3_0DisableState])
+	assert.Equal(t, OutputDisableLow, device.Clk1().DisableState)
+	assert.Equal(t, OutputDisableNever, device.Clk0().DisableState)
+	assert.Equal(t, OutputDisableNever, device.Clk3().DisableState)
+}
+
+func TestSetOEBControl(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	bus.registers[RegOebPinEnableControl] = 0xFF
+	device.Load()
+	assert.False(t, device.Clk2().OEBControlled)
+
+	err := device.SetOEBControl(true, Clk2, Clk5)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0xDB), bus.registers[RegOebPinEnableControl])
+	assert.True(t, device.Clk2().OEBControlled)
+	assert.True(t, device.Clk5().OEBControlled)
+	assert.False(t, device.Clk0().OEBControlled)
+
+	err = device.Clk5().SetOEBControl(false)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0xFB), bus.registers[RegOebPinEnableControl])
+	assert.False(t, device.Clk5().OEBControlled)
+}
+
+func TestAutomaticIntegerMode(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.StartSetup()
+
+	_, err := device.SetupPLL(PLLA, 900*MHz)
+	assert.NoError(t, err)
+	assert.True(t, device.PLLA().IntegerMode)
+	assert.Equal(t, byte(1<<6), bus.registers[RegClk6Control]&(1<<6))
+
+	_, err = device.SetupPLL(PLLB, 899500*KHz)
+	assert.NoError(t, err)
+	assert.False(t, device.PLLB().IntegerMode)
+	assert.Equal(t, byte(0), bus.registers[RegClk7Control]&(1<<6))
+
+	device.PrepareOutputs(PLLA, false, ClockInputMultisynth, OutputDrive2mA, OutputDisableLow, Clk0, Clk6)
+	_, err = device.SetOutputFrequency(Clk0, 10*MHz)
+	assert.NoError(t, err)
+	assert.True(t, device.Clk0().IntegerMode)
+	assert.Equal(t, byte(1<<6), bus.registers[RegClk0Control]&(1<<6))
+
+	_, err = device.SetOutputFrequency(Clk0, 7074*KHz)
+	assert.NoError(t, err)
+	assert.False(t, device.Clk0().IntegerMode)
+	assert.Equal(t, byte(0), bus.registers[RegClk0Control]&(1<<6))
+
+	assert.NoError(t, device.Clk6().SetupControl(false, false, PLLB, false, ClockInputMultisynth, OutputDrive8mA))
+	assert.Equal(t, byte(1<<6), bus.registers[RegClk6Control]&(1<<6))
+	assert.Equal(t, PLLB, device.Clk6().PLL)
+
+	assert.NoError(t, device.Shutdown())
+	assert.Equal(t, byte(0x80|1<<6), bus.registers[RegClk6Control])
+	assert.Equal(t, byte(0x80), bus.registers[RegClk7Control])
+
+	loaded := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	assert.NoError(t, loaded.Load())
+	assert.True(t, loaded.PLLA().IntegerMode)
+	assert.False(t, loaded.PLLB().IntegerMode)
+	assert.False(t, loaded.Clk6().IntegerMode)
+}
+
+func TestPLLIntegerModeWithoutLoad(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	bus.registers[RegClk6Control] = 0x80
+	bus.registers[RegClk7Control] = 0xC0
+
+	_, err := device.SetupPLL(PLLA, 900*MHz)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0xC0), bus.registers[RegClk6Control])
+	assert.True(t, device.Clk6().PowerDown)
+
+	_, err = device.SetupPLL(PLLB, 899500*KHz)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x80), bus.registers[RegClk7Control])
+	assert.False(t, device.PLLB().IntegerMode)
+}
+
+func TestOutputControlWithoutLoad(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	bus.registers[RegClk6Control] = 0x80 | 1<<5 | 1<<4 | byte(OutputDrive6mA)
+
+	err := device.Clk6().SetPowerDown(false)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(1<<5|1<<4|byte(OutputDrive6mA)), bus.registers[RegClk6Control])
+	assert.False(t, device.Clk6().PowerDown)
+	assert.Equal(t, PLLB, device.Clk6().PLL)
+	assert.True(t, device.Clk6().Invert)
+	assert.Equal(t, OutputDrive6mA, device.Clk6().Drive)
+}
+
+func TestRangeChecksBeforeWriting(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.SetupPLL(PLLA, 900*MHz)
+	writes := 0
+	bus.onWrite = func(uint8, []byte) { writes++ }
+
+	_, err := device.SetupPLL(PLLA, 950*MHz)
+	assert.True(t, errors.Is(err, ErrPLLOutOfRange), "%v", err)
+	_, err = device.SetupPLL(PLLA, 500*MHz)
+	assert.True(t, errors.Is(err, ErrPLLOutOfRange), "%v", err)
+	_, err = device.SetOutputFrequency(Clk0, 2*KHz)
+	assert.True(t, errors.Is(err, ErrOutputOutOfRange), "%v", err)
+	_, err = device.SetOutputFrequency(Clk0, 201*MHz)
+	assert.True(t, errors.Is(err, ErrOutputOutOfRange), "%v", err)
+	_, err = device.SetOutputFrequency(Clk6, 10*KHz)
+	assert.True(t, errors.Is(err, ErrOutputOutOfRange), "%v", err)
+	_, err = device.SetOutputFrequency(Clk6, 160*MHz)
+	assert.True(t, errors.Is(err, ErrOutputOutOfRange), "%v", err)
+	_, _, err = device.SetupQuadratureOutput(PLLA, Clk0, Clk6, 10*MHz)
+	assert.True(t, errors.Is(err, ErrUnsupportedOutput), "%v", err)
+
+	assert.Equal(t, 0, writes)
+	assert.Equal(t, FractionalRatio{A: 36, B: 0, C: 1}, device.PLLA().Multiplier)
+}
+
+func TestWriteErrors(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.SetupPLL(PLLA, 900*MHz)
+	device.SetOutputFrequency(Clk0, 10*MHz)
+	busErr := errors.New("bus error")
+	bus.writeErr = busErr
+
+	_, err := device.SetupPLL(PLLA, 800*MHz)
+	assert.True(t, errors.Is(err, busErr), "%v", err)
+	assert.Contains(t, err.Error(), "register 26")
+	assert.Equal(t, FractionalRatio{A: 36, B: 0, C: 1}, device.PLLA().Multiplier)
+
+	_, err = device.SetOutputFrequency(Clk0, 7*MHz)
+	assert.True(t, errors.Is(err, busErr), "%v", err)
+	assert.Equal(t, FractionalRatio{A: 90, B: 0, C: 1}, device.Clk0().FrequencyDivider)
+
+	assert.True(t, errors.Is(device.Clk0().SetDrive(OutputDrive8mA), busErr))
+	assert.Equal(t, OutputDrive2mA, device.Clk0().Drive)
+	assert.True(t, errors.Is(device.StartSetup(), busErr))
+	assert.True(t, errors.Is(device.FinishSetup(), busErr))
+
+	bus.writeErr = nil
+	_, err = device.SetOutputFrequency(Clk0, 7*MHz)
+	assert.NoError(t, err)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.StartSetup()
+	device.SetupPLL(PLLB, 800*MHz)
+	device.PrepareOutputs(PLLB, false, ClockInputMultisynth, OutputDrive2mA, OutputDisableLow, Clk4)
+	device.SetOutputFrequency(Clk4, 10*MHz)
+	device.FinishSetup()
+
+	// all writes of SetupQuadratureOutput go to these registers, the PLL reset ends the sequence
+	quadratureRegisters := map[uint8]bool{
+		RegClk0Control:              true,
+		RegClk1Control:              true,
+		RegClk6Control:              true,
+		RegPLLAMultisynthParameters: true,
+		RegMultisynth0Parameters:    true,
+		RegMultisynth1Parameters:    true,
+		RegClk0InitialPhaseOffset:   true,
+		RegClk1InitialPhaseOffset:   true,
+	}
+	inQuadrature := false
+	var interleaved []uint8
+	bus.onWrite = func(reg uint8, values []byte) {
+		switch {
+		case reg == RegClk0Control:
+			inQuadrature = true
+		case reg == RegPLLReset:
+			inQuadrature = false
+		case inQuadrature && !quadratureRegisters[reg]:
+			interleaved = append(interleaved, reg)
+		}
+	}
+
+	const n = 50
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				f(i)
+			}
+		}()
+	}
+	run(func(i int) {
+		_, _, err := device.SetupQuadratureOutput(PLLA, Clk0, Clk1, 7*MHz+Frequency(i)*KHz)
+		assert.NoError(t, err)
+	})
+	run(func(i int) {
+		_, err := device.Tune(Clk4, 10*MHz+Frequency(i)*10*Hz)
+		assert.NoError(t, err)
+	})
+	run(func(i int) {
+		assert.NoError(t, device.Clk2().SetDrive(OutputDrive(i%4)))
+		assert.NoError(t, device.Clk2().Enable(i%2 == 0))
+	})
+	run(func(i int) {
+		assert.NoError(t, device.Clk3().SetDisableState(OutputDisableState(i%4)))
+		_, err := device.Status()
+		assert.NoError(t, err)
+		device.Inspect(func() {
+			assert.Equal(t, PLLB, device.Clk4().PLL)
+		})
+	})
+	wg.Wait()
+
+	assert.Empty(t, interleaved)
+	loaded := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	assert.NoError(t, loaded.Load())
+	for _, reg := range sharedRegisterAddresses {
+		assert.Equal(t, bus.registers[reg], device.shared.values[reg], "register %d", reg)
+	}
+	assert.Equal(t, device.Clk4().FrequencyDivider, loaded.Clk4().FrequencyDivider)
+	assert.Equal(t, device.PLLA().Multiplier, loaded.PLLA().Multiplier)
+}
@@ -0,0 +1,178 @@
+package si5351
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SpreadSpectrumMode describes the mode of the spread spectrum modulation.
+type SpreadSpectrumMode uint8
+
+// All spread spectrum modes.
+const (
+	SpreadSpectrumOff SpreadSpectrumMode = iota
+	SpreadSpectrumDown
+	SpreadSpectrumCenter
+)
+
+// DefaultSpreadSpectrumRate is the modulation rate recommended by the datasheet.
+const DefaultSpreadSpectrumRate = 31500 * Hz
+
+// The limits of the spread spectrum amplitude in percent.
+const (
+	MinSpreadSpectrumAmplitude       = 0.1
+	MaxDownSpreadSpectrumAmplitude   = 2.5
+	MaxCenterSpreadSpectrumAmplitude = 1.5
+)
+
+// SpreadSpectrum describes the spread spectrum configuration of a PLL. Only PLL A supports spread spectrum.
+type SpreadSpectrum struct {
+	Mode SpreadSpectrumMode
+	// Amplitude of the spread in percent. With down spread, the frequency varies between -Amplitude and 0,
+	// with center spread between -Amplitude and +Amplitude.
+	Amplitude float64
+	// Rate is the modulation rate. If it is 0, DefaultSpreadSpectrumRate is used.
+	Rate Frequency
+}
+
+// SpreadSpectrumParameters represents the spread spectrum configuration in the Si5351's registers.
+type SpreadSpectrumParameters struct {
+	Enabled bool
+	Center  bool
+	UpDown  uint32
+	DownP1  uint32
+	DownP2  uint32
+	DownP3  uint32
+	UpP1    uint32
+	UpP2    uint32
+	UpP3    uint32
+}
+
+// Parameters calculates the register parameters of the spread spectrum modulation of a PLL that runs with
+// the given multiplier from the given reference frequency.
+func (ss SpreadSpectrum) Parameters(refFrequency Frequency, multiplier FractionalRatio) (SpreadSpectrumParameters, error) {
+	const denominator = 0x7FFF
+
+	if ss.Mode == SpreadSpectrumOff {
+		return SpreadSpectrumParameters{}, nil
+	}
+
+	maxAmplitude := MaxDownSpreadSpectrumAmplitude
+	if ss.Mode == SpreadSpectrumCenter {
+		maxAmplitude = MaxCenterSpreadSpectrumAmplitude
+	}
+	if ss.Amplitude < MinSpreadSpectrumAmplitude || ss.Amplitude > maxAmplitude {
+		return SpreadSpectrumParameters{}, fmt.Errorf("invalid spread spectrum amplitude %.2f%%, must be within %.1f%%-%.1f%%", ss.Amplitude, MinSpreadSpectrumAmplitude, maxAmplitude)
+	}
+	if multiplier.A == 0 {
+		return SpreadSpectrumParameters{}, errors.New("the PLL must be set up before the spread spectrum")
+	}
+
+	rate := ss.Rate
+	if rate == 0 {
+		rate = DefaultSpreadSpectrumRate
+	}
+	upDown := uint32(refFrequency / (4 * rate))
+	if upDown == 0 || upDown > 0xFFF {
+		return SpreadSpectrumParameters{}, fmt.Errorf("invalid spread spectrum rate %.0fHz", rate)
+	}
+
+	ratio := float64(multiplier.Multiply(1))
+	amplitude := ss.Amplitude / 100
+	result := SpreadSpectrumParameters{
+		Enabled: true,
+		UpDown:  upDown,
+		DownP3:  denominator,
+		UpP3:    1,
+	}
+
+	var down float64
+	switch ss.Mode {
+	case SpreadSpectrumDown:
+		down = 64 * ratio * amplitude / ((1 + amplitude) * float64(upDown))
+	case SpreadSpectrumCenter:
+		up := 128 * ratio * amplitude / ((1 - amplitude) * float64(upDown))
+		down = 128 * ratio * amplitude / ((1 + amplitude) * float64(upDown))
+		result.Center = true
+		result.UpP1 = uint32(up)
+		result.UpP2 = uint32(denominator * (up - float64(result.UpP1)))
+		result.UpP3 = denominator
+	default:
+		return SpreadSpectrumParameters{}, fmt.Errorf("invalid spread spectrum mode %d", ss.Mode)
+	}
+	result.DownP1 = uint32(down)
+	result.DownP2 = uint32(denominator * (down - float64(result.DownP1)))
+
+	if result.DownP1 > 0xFFF || result.UpP1 > 0xFFF {
+		return SpreadSpectrumParameters{}, fmt.Errorf("spread spectrum amplitude %.2f%% too large for this PLL frequency", ss.Amplitude)
+	}
+	return result, nil
+}
+
+// Bytes returns the representation of the spread spectrum parameters in the Si5351's registers 149-161 as bytes.
+func (p SpreadSpectrumParameters) Bytes() []byte {
+	bytes := []byte{
+		byte((p.DownP2 & 0x7F00) >> 8),
+		byte(p.DownP2 & 0x00FF),
+		byte((p.DownP3 & 0x7F00) >> 8),
+		byte(p.DownP3 & 0x00FF),
+		byte(p.DownP1 & 0x00FF),
+		byte((p.UpDown&0x0F00)>>4) | byte((p.DownP1&0x0F00)>>8),
+		byte(p.UpDown & 0x00FF),
+		byte((p.UpP2 & 0x7F00) >> 8),
+		byte(p.UpP2 & 0x00FF),
+		byte((p.UpP3 & 0x7F00) >> 8),
+		byte(p.UpP3 & 0x00FF),
+		byte(p.UpP1 & 0x00FF),
+		byte((p.UpP1 & 0x0F00) >> 8),
+	}
+	if p.Enabled {
+		bytes[0] |= 0x80
+	}
+	if p.Center {
+		bytes[2] |= 0x80
+	}
+	return bytes
+}
+
+// SetupSpreadSpectrum writes the given spread spectrum configuration into the registers of the PLL.
+// Set up the multiplier of the PLL first.
+func (p *PLL) SetupSpreadSpectrum(refFrequency Frequency, ss SpreadSpectrum) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.setupSpreadSpectrum(refFrequency, ss)
+}
+
+func (p *PLL) setupSpreadSpectrum(refFrequency Frequency, ss SpreadSpectrum) error {
+	if p.Register.SpreadSpectrum == 0 {
+		return errors.New("the PLL does not support spread spectrum")
+	}
+
+	parameters, err := ss.Parameters(refFrequency, p.Multiplier)
+	if err != nil {
+		return err
+	}
+
+	if err := writeRegisters(p.bus, p.Register.SpreadSpectrum, parameters.Bytes()...); err != nil {
+		return err
+	}
+	p.SpreadSpectrum = ss
+	return nil
+}
+
+// SetupSpreadSpectrum enables the given spread spectrum configuration on PLL A. Spread spectrum requires all Multisynths
+// that are driven by PLL A to be in integer mode, therefore set up the PLL and the outputs first.
+func (s *Si5351) SetupSpreadSpectrum(ss SpreadSpectrum) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if ss.Mode != SpreadSpectrumOff {
+		for _, o := range s.fractionalOutput {
+			if !o.PowerDown && o.PLL == PLLA && o.InputSource == ClockInputMultisynth && !o.IntegerMode {
+				return fmt.Errorf("spread spectrum requires integer mode, but CLK%d uses a fractional divider", o.index())
+			}
+		}
+	}
+
+	return s.PLLA().setupSpreadSpectrum(s.referenceFrequency(PLLA), ss)
+}
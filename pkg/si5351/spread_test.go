@@ -0,0 +1,66 @@
+package si5351
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpreadSpectrumParametersDown(t *testing.T) {
+	ss := SpreadSpectrum{Mode: SpreadSpectrumDown, Amplitude: 1}
+
+	parameters, err := ss.Parameters(25*MHz, FractionalRatio{A: 36, B: 0, C: 1})
+
+	assert.NoError(t, err)
+	assert.Equal(t, SpreadSpectrumParameters{Enabled: true, UpDown: 198, DownP1: 0, DownP2: 3775, DownP3: 0x7FFF, UpP3: 1}, parameters)
+	assert.Equal(t, []byte{0x8E, 0xBF, 0x7F, 0xFF, 0x00, 0x00, 0xC6, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00}, parameters.Bytes())
+}
+
+func TestSpreadSpectrumParametersCenter(t *testing.T) {
+	ss := SpreadSpectrum{Mode: SpreadSpectrumCenter, Amplitude: 0.5}
+
+	parameters, err := ss.Parameters(25*MHz, FractionalRatio{A: 36, B: 0, C: 1})
+
+	assert.NoError(t, err)
+	assert.True(t, parameters.Center)
+	assert.Equal(t, uint32(198), parameters.UpDown)
+	assert.Equal(t, uint32(0x7FFF), parameters.UpP3)
+	assert.True(t, parameters.UpP2 > parameters.DownP2)
+	assert.Equal(t, byte(0x80), parameters.Bytes()[2]&0x80)
+}
+
+func TestSpreadSpectrumParametersInvalid(t *testing.T) {
+	multiplier := FractionalRatio{A: 36, B: 0, C: 1}
+
+	_, err := SpreadSpectrum{Mode: SpreadSpectrumDown, Amplitude: 3}.Parameters(25*MHz, multiplier)
+	assert.Error(t, err)
+
+	_, err = SpreadSpectrum{Mode: SpreadSpectrumCenter, Amplitude: 2}.Parameters(25*MHz, multiplier)
+	assert.Error(t, err)
+
+	_, err = SpreadSpectrum{Mode: SpreadSpectrumDown, Amplitude: 1}.Parameters(25*MHz, FractionalRatio{})
+	assert.Error(t, err)
+}
+
+func TestSetupSpreadSpectrumRequiresIntegerMode(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.SetupPLL(PLLA, 900*MHz)
+	device.PrepareOutputs(PLLA, false, ClockInputMultisynth, OutputDrive2mA, OutputDisableLow, Clk0)
+	device.SetOutputFrequency(Clk0, 7*MHz)
+	ss := SpreadSpectrum{Mode: SpreadSpectrumDown, Amplitude: 1}
+
+	err := device.SetupSpreadSpectrum(ss)
+	assert.Error(t, err)
+
+	device.SetOutputDivider(Clk0, 90, 0, 1)
+	device.Clk0().SetIntegerMode(true)
+
+	err = device.SetupSpreadSpectrum(ss)
+	assert.NoError(t, err)
+	assert.Equal(t, ss, device.PLLA().SpreadSpectrum)
+	assert.Equal(t, byte(0x80), bus.registers[RegSpreadSpectrumParameters]&0x80)
+
+	err = device.PLLB().SetupSpreadSpectrum(device.Crystal.Frequency(), ss)
+	assert.Error(t, err)
+}
@@ -0,0 +1,203 @@
+package si5351
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StatusBits describes the bits of the device status, the sticky interrupt status, and the interrupt mask register.
+type StatusBits uint8
+
+// All status bits.
+const (
+	StatusSystemInit          StatusBits = 1 << 7
+	StatusLossOfLockB         StatusBits = 1 << 6
+	StatusLossOfLockA         StatusBits = 1 << 5
+	StatusLossOfSignalClkin   StatusBits = 1 << 4
+	StatusLossOfSignalCrystal StatusBits = 1 << 3
+
+	AllStatusBits = StatusSystemInit | StatusLossOfLockB | StatusLossOfLockA | StatusLossOfSignalClkin | StatusLossOfSignalCrystal
+)
+
+// Status represents the decoded status of the Si5351.
+type Status struct {
+	// SystemInit indicates that the device is still initializing.
+	SystemInit bool
+	// LossOfLockA indicates that PLL A is not locked.
+	LossOfLockA bool
+	// LossOfLockB indicates that PLL B is not locked.
+	LossOfLockB bool
+	// LossOfSignalClkin indicates that there is no signal on the CLKIN input.
+	LossOfSignalClkin bool
+	// LossOfSignalCrystal indicates that the crystal does not oscillate.
+	LossOfSignalCrystal bool
+	// Revision is the silicon revision of the device, it is only available in the device status.
+	Revision uint8
+}
+
+func decodeStatus(value byte) Status {
+	bits := StatusBits(value)
+	return Status{
+		SystemInit:          bits&StatusSystemInit != 0,
+		LossOfLockA:         bits&StatusLossOfLockA != 0,
+		LossOfLockB:         bits&StatusLossOfLockB != 0,
+		LossOfSignalClkin:   bits&StatusLossOfSignalClkin != 0,
+		LossOfSignalCrystal: bits&StatusLossOfSignalCrystal != 0,
+		Revision:            value & 0x03,
+	}
+}
+
+// LossOfLock indicates if the given PLL is not locked.
+func (s Status) LossOfLock(pll PLLIndex) bool {
+	if pll == PLLB {
+		return s.LossOfLockB
+	}
+	return s.LossOfLockA
+}
+
+// Bits returns the status flags as StatusBits.
+func (s Status) Bits() StatusBits {
+	var result StatusBits
+	if s.SystemInit {
+		result |= StatusSystemInit
+	}
+	if s.LossOfLockA {
+		result |= StatusLossOfLockA
+	}
+	if s.LossOfLockB {
+		result |= StatusLossOfLockB
+	}
+	if s.LossOfSignalClkin {
+		result |= StatusLossOfSignalClkin
+	}
+	if s.LossOfSignalCrystal {
+		result |= StatusLossOfSignalCrystal
+	}
+	return result
+}
+
+// Status reads the current device status.
+func (s *Si5351) Status() (Status, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.status()
+}
+
+func (s *Si5351) status() (Status, error) {
+	value := make([]byte, 1)
+	if err := readRegisters(s.bus, RegDeviceStatus, value); err != nil {
+		return Status{}, err
+	}
+	return decodeStatus(value[0]), nil
+}
+
+// StickyStatus reads the sticky interrupt status. A flag in the sticky status remains set until it is cleared
+// using ClearStickyStatus, even if the condition does not persist.
+func (s *Si5351) StickyStatus() (Status, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value := make([]byte, 1)
+	if err := readRegisters(s.bus, RegInterruptStatusSticky, value); err != nil {
+		return Status{}, err
+	}
+	result := decodeStatus(value[0])
+	result.Revision = 0
+	return result, nil
+}
+
+// ClearStickyStatus clears the given bits of the sticky interrupt status.
+func (s *Si5351) ClearStickyStatus(bits StatusBits) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value := make([]byte, 1)
+	if err := readRegisters(s.bus, RegInterruptStatusSticky, value); err != nil {
+		return err
+	}
+	return writeRegisters(s.bus, RegInterruptStatusSticky, value[0]&^byte(bits&AllStatusBits))
+}
+
+// SetInterruptMask sets the interrupt mask. The given bits do not assert the interrupt pin of the Si5351.
+func (s *Si5351) SetInterruptMask(mask StatusBits) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := writeRegisters(s.bus, RegInterruptStatusMask, byte(mask&AllStatusBits))
+	if err == nil {
+		s.InterruptMask = mask & AllStatusBits
+	}
+	return err
+}
+
+// LockPollInterval is the interval in which WaitForLock polls the device status.
+const LockPollInterval = time.Millisecond
+
+// ErrNotLocked indicates that a PLL is not locked.
+var ErrNotLocked = errors.New("PLL not locked")
+
+// LockError indicates that a PLL did not lock in time. It matches ErrNotLocked with errors.Is.
+type LockError struct {
+	PLL PLLIndex
+	Err error
+}
+
+func (e *LockError) Error() string {
+	return fmt.Sprintf("PLL %v not locked: %v", e.PLL, e.Err)
+}
+
+// Unwrap returns the reason why waiting for the lock was aborted.
+func (e *LockError) Unwrap() error {
+	return e.Err
+}
+
+// Is indicates if the target is ErrNotLocked.
+func (e *LockError) Is(target error) bool {
+	return target == ErrNotLocked
+}
+
+// WaitForLock polls the device status until the given PLL is locked. If the context is done before the PLL is locked,
+// WaitForLock returns a *LockError. Other goroutines may use the Si5351 between two polls.
+func (s *Si5351) WaitForLock(ctx context.Context, pll PLLIndex) error {
+	return waitForLock(ctx, pll, s.Status)
+}
+
+// waitForLock polls the given status function until the given PLL is locked.
+func waitForLock(ctx context.Context, pll PLLIndex, readStatus func() (Status, error)) error {
+	ticker := time.NewTicker(LockPollInterval)
+	defer ticker.Stop()
+	for {
+		status, err := readStatus()
+		if err != nil {
+			return err
+		}
+		if !status.SystemInit && !status.LossOfLock(pll) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return &LockError{PLL: pll, Err: ctx.Err()}
+		case <-ticker.C:
+		}
+	}
+}
+
+// awaitLock waits for the given PLLs to lock if the LockTimeout is set.
+// The caller must hold the lock of the Si5351, it is kept while waiting.
+func (s *Si5351) awaitLock(plls ...PLLIndex) error {
+	if s.LockTimeout == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.LockTimeout)
+	defer cancel()
+	for _, pll := range plls {
+		if err := waitForLock(ctx, pll, s.status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package si5351
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	bus.registers[RegDeviceStatus] = byte(StatusLossOfLockB|StatusLossOfSignalClkin) | 0x02
+
+	status, err := device.Status()
+
+	assert.NoError(t, err)
+	assert.Equal(t, Status{LossOfLockB: true, LossOfSignalClkin: true, Revision: 2}, status)
+	assert.True(t, status.LossOfLock(PLLB))
+	assert.False(t, status.LossOfLock(PLLA))
+	assert.Equal(t, StatusLossOfLockB|StatusLossOfSignalClkin, status.Bits())
+}
+
+func TestStickyStatus(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	bus.registers[RegInterruptStatusSticky] = byte(StatusSystemInit | StatusLossOfLockA | StatusLossOfSignalCrystal)
+
+	status, err := device.StickyStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, Status{SystemInit: true, LossOfLockA: true, LossOfSignalCrystal: true}, status)
+
+	err = device.ClearStickyStatus(StatusSystemInit | StatusLossOfLockA)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(StatusLossOfSignalCrystal), bus.registers[RegInterruptStatusSticky])
+}
+
+func TestSetInterruptMask(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+
+	err := device.SetInterruptMask(StatusLossOfSignalClkin | 0x03)
+
+	assert.NoError(t, err)
+	assert.Equal(t, StatusLossOfSignalClkin, device.InterruptMask)
+	assert.Equal(t, byte(StatusLossOfSignalClkin), bus.registers[RegInterruptStatusMask])
+}
+
+func TestWaitForLock(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	bus.registers[RegDeviceStatus] = byte(StatusLossOfLockA | StatusLossOfLockB)
+	reads := 0
+	bus.onRead = func(reg uint8) {
+		reads++
+		if reads == 3 {
+			bus.registers[RegDeviceStatus] = byte(StatusLossOfLockB)
+		}
+	}
+
+	err := device.WaitForLock(context.Background(), PLLA)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, reads)
+}
+
+func TestWaitForLockTimeout(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	bus.registers[RegDeviceStatus] = byte(StatusLossOfLockB)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	err := device.WaitForLock(ctx, PLLB)
+
+	var lockErr *LockError
+	assert.True(t, errors.As(err, &lockErr))
+	assert.Equal(t, PLLB, lockErr.PLL)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, errors.Is(err, ErrNotLocked))
+}
+
+func TestFinishSetupWaitsForLock(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.LockTimeout = 5 * time.Millisecond
+	device.StartSetup()
+	device.PrepareOutputs(PLLB, false, ClockInputMultisynth, OutputDrive2mA, OutputDisableLow, Clk0)
+	bus.registers[RegDeviceStatus] = byte(StatusLossOfLockB)
+
+	err := device.FinishSetup()
+
+	assert.Error(t, err)
+	assert.False(t, device.Clk0().Enabled)
+	assert.Equal(t, byte(0xFF), bus.registers[RegOutputEnableControl])
+
+	bus.registers[RegDeviceStatus] = byte(StatusLossOfLockA)
+
+	err = device.FinishSetup()
+
+	assert.NoError(t, err)
+	assert.True(t, device.Clk0().Enabled)
+}
+
+func TestLockTimeoutThroughI2CBus(t *testing.T) {
+	i2cDevice := &fakeI2CDevice{}
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, &I2CBus{device: i2cDevice})
+	device.LockTimeout = 5 * time.Millisecond
+	i2cDevice.registers[RegDeviceStatus] = byte(StatusLossOfLockA)
+
+	status, err := device.Status()
+	assert.NoError(t, err)
+	assert.True(t, status.LossOfLock(PLLA))
+
+	_, err = device.SetupPLL(PLLA, 800*MHz)
+	assert.True(t, errors.Is(err, ErrNotLocked))
+
+	i2cDevice.registers[RegDeviceStatus] = 0
+	_, err = device.SetupPLL(PLLA, 800*MHz)
+	assert.NoError(t, err)
+}
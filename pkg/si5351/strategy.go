@@ -0,0 +1,247 @@
+package si5351
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// TuningRatios are the multiplier of a PLL and the divider of an output's Multisynth that generate a frequency.
+type TuningRatios struct {
+	Multiplier FractionalRatio
+	Divider    FractionalRatio
+	// Reset indicates that the ratios cannot be applied smoothly and the PLL must be reset.
+	Reset bool
+}
+
+// TuningStrategy decides how the PLL and the Multisynth of an output share the work to generate a frequency.
+// Strategies trade phase noise against the speed and smoothness of frequency changes. See Si5351.Tune.
+type TuningStrategy interface {
+	// Ratios calculates the ratios that generate the given frequency from the given reference frequency, starting from the
+	// current ratios of the PLL and the output.
+	Ratios(refFrequency Frequency, current TuningRatios, frequency Frequency) (TuningRatios, error)
+}
+
+// FixedPLLStrategy keeps the PLL at a fixed frequency and tunes the fractional Multisynth of the output. This allows fast
+// frequency changes without touching the PLL, e.g. for FSK, and several outputs with different frequencies on one PLL.
+// The divider uses the fixed TuningDenominator, small steps only change a few bytes of the Multisynth parameters.
+// Frequencies above 150MHz use the divide-by-4 mode, this moves the PLL to four times the frequency and resets it.
+type FixedPLLStrategy struct {
+	// PLLFrequency is the frequency of the PLL. If it is zero, the PLL keeps its current frequency,
+	// an unused PLL is set to 900MHz.
+	PLLFrequency Frequency
+}
+
+// Ratios implements the TuningStrategy interface.
+func (t FixedPLLStrategy) Ratios(refFrequency Frequency, current TuningRatios, frequency Frequency) (TuningRatios, error) {
+	if err := checkTuningFrequency(refFrequency, frequency); err != nil {
+		return TuningRatios{}, err
+	}
+	if frequency > MinBy4Frequency {
+		return by4Ratios(refFrequency, current, frequency), nil
+	}
+
+	multiplier := current.Multiplier
+	pllFrequency := t.PLLFrequency
+	if pllFrequency == 0 && multiplier.Rat().Sign() == 0 {
+		pllFrequency = MaxPLLFrequency
+	}
+	if pllFrequency != 0 {
+		if err := checkPLLFrequency(pllFrequency); err != nil {
+			return TuningRatios{}, err
+		}
+		var err error
+		multiplier, err = FindFractionalMultiplier(refFrequency, pllFrequency)
+		if err != nil {
+			return TuningRatios{}, err
+		}
+	}
+
+	divider, err := FindFractionalDivider(multiplier.Multiply(refFrequency), frequency)
+	if err != nil {
+		return TuningRatios{}, err
+	}
+	q := new(big.Rat).Quo(multiplier.MultiplyExact(refFrequency.Rat()), frequency.Rat())
+	q.Quo(q, new(big.Rat).SetInt64(int64(divider.ClockDivider.Factor())))
+	clockDivider := divider.ClockDivider
+	divider = ratioWithDenominator(q, TuningDenominator)
+	divider.ClockDivider = clockDivider
+
+	return TuningRatios{
+		Multiplier: multiplier,
+		Divider:    divider,
+		Reset:      multiplier != current.Multiplier,
+	}, nil
+}
+
+// IntegerDividerStrategy uses an even integer divider in the Multisynth of the output and moves the PLL with a fractional
+// multiplier, like FindFractionalMultiplierWithIntegerDivider. The integer divider gives the lowest phase noise, but
+// the divider changes often while tuning, and each change resets the PLL.
+type IntegerDividerStrategy struct{}
+
+// Ratios implements the TuningStrategy interface.
+func (t IntegerDividerStrategy) Ratios(refFrequency Frequency, current TuningRatios, frequency Frequency) (TuningRatios, error) {
+	if err := checkTuningFrequency(refFrequency, frequency); err != nil {
+		return TuningRatios{}, err
+	}
+
+	multiplier, divider, err := FindFractionalMultiplierWithIntegerDivider(refFrequency, frequency)
+	if err != nil {
+		return TuningRatios{}, err
+	}
+	return TuningRatios{
+		Multiplier: multiplier,
+		Divider:    divider,
+		Reset:      divider != current.Divider || current.Multiplier.Rat().Sign() == 0,
+	}, nil
+}
+
+// HybridStrategy keeps an even integer divider in the Multisynth of the output across a band and moves only the PLL,
+// without resetting it. Only if the PLL would leave its range of 600-900MHz, the strategy changes over to another divider
+// that puts the PLL into the middle of its range, and resets the PLL. This combines the low phase noise of an integer
+// divider with smooth tuning, e.g. for receivers.
+// The multiplier uses the fixed TuningDenominator, small steps only change a few bytes of the PLL parameters.
+type HybridStrategy struct{}
+
+// Ratios implements the TuningStrategy interface.
+func (t HybridStrategy) Ratios(refFrequency Frequency, current TuningRatios, frequency Frequency) (TuningRatios, error) {
+	if err := checkTuningFrequency(refFrequency, frequency); err != nil {
+		return TuningRatios{}, err
+	}
+	if frequency > MinBy4Frequency {
+		return by4Ratios(refFrequency, current, frequency), nil
+	}
+
+	divider := current.Divider
+	reset := false
+	if divider.By4 || !divider.IsInteger() || !withinPLLRange(frequency*Frequency(divider.A)*Frequency(divider.ClockDivider.Factor())) {
+		var err error
+		divider, err = centeredIntegerDivider(frequency)
+		if err != nil {
+			return TuningRatios{}, err
+		}
+		reset = true
+	}
+
+	pllFrequency := frequency * Frequency(divider.A) * Frequency(divider.ClockDivider.Factor())
+	return TuningRatios{
+		Multiplier: tuningMultiplier(refFrequency, pllFrequency),
+		Divider:    divider,
+		Reset:      reset || current.Multiplier.Rat().Sign() == 0,
+	}, nil
+}
+
+// checkTuningFrequency checks if the given frequency can be generated on CLK0-CLK5 from the given reference frequency.
+func checkTuningFrequency(refFrequency, frequency Frequency) error {
+	if refFrequency <= 0 {
+		return errors.New("the reference frequency is not set up")
+	}
+	return checkOutputFrequency(frequency)
+}
+
+// by4Ratios returns the ratios for frequencies above 150MHz. Within the divide-by-4 mode, only the PLL moves.
+func by4Ratios(refFrequency Frequency, current TuningRatios, frequency Frequency) TuningRatios {
+	return TuningRatios{
+		Multiplier: tuningMultiplier(refFrequency, 4*frequency),
+		Divider:    FractionalRatio{A: 4, B: 0, C: 1, By4: true},
+		Reset:      !current.Divider.By4,
+	}
+}
+
+// centeredIntegerDivider returns the even integer divider and the R divider that generate the given frequency with a PLL
+// frequency as close as possible to the middle of the PLL's range. This leaves the most room to tune the PLL.
+func centeredIntegerDivider(frequency Frequency) (FractionalRatio, error) {
+	const minA, maxA = MinMultisynthDivider, MaxMultisynthDivider
+	center := (MinPLLFrequency + MaxPLLFrequency) / 2
+
+	for r := ClockBy1; r <= ClockBy128; r++ {
+		q := float64(center / (frequency * Frequency(r.Factor())))
+		a := 2 * uint32(q/2+0.5)
+		if a > maxA {
+			continue
+		}
+		if a < minA {
+			a = minA
+		}
+		if !withinPLLRange(frequency * Frequency(a) * Frequency(r.Factor())) {
+			break
+		}
+		return FractionalRatio{A: a, B: 0, C: 1, ClockDivider: r}, nil
+	}
+	return FractionalRatio{}, &RangeError{What: "integer divider", Frequency: frequency, Min: center / (maxA * Frequency(ClockBy128.Factor())), Max: MaxOutputFrequency, Err: ErrOutputOutOfRange}
+}
+
+// tuningMultiplier returns the multiplier with the fixed TuningDenominator that is closest to the given PLL frequency.
+func tuningMultiplier(refFrequency, pllFrequency Frequency) FractionalRatio {
+	q := new(big.Rat).Quo(pllFrequency.Rat(), refFrequency.Rat())
+	return ratioWithDenominator(q, TuningDenominator)
+}
+
+func withinPLLRange(frequency Frequency) bool {
+	return frequency >= MinPLLFrequency && frequency <= MaxPLLFrequency
+}
+
+// Tune sets the given output to the closest possible value of the given frequency, using the TuningStrategy of the Si5351.
+// The output must be one of CLK0-CLK5 and must be associated with its PLL, e.g. with PrepareOutputs. If the strategy
+// does not require a reset of the PLL, only the bytes of the parameters that actually change are written, see TuneOutput
+// and TunePLL. If the strategy moves the PLL, all other outputs that are driven by the PLL follow the frequency change.
+// The method returns the exact effective output frequency.
+func (s *Si5351) Tune(output OutputIndex, frequency Frequency) (Tuning, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Variant.checkOutput(output); err != nil {
+		return Tuning{}, err
+	}
+	if output >= Clk6 {
+		return Tuning{}, fmt.Errorf("only CLK0-CLK5 can be tuned: %w", ErrUnsupportedOutput)
+	}
+
+	o := s.fractionalOutput[output]
+	p := s.pll[o.PLL]
+	current := TuningRatios{Multiplier: p.Multiplier, Divider: o.FrequencyDivider}
+	next, err := s.tuningStrategy().Ratios(s.referenceFrequency(o.PLL), current, frequency)
+	if err != nil {
+		return Tuning{}, err
+	}
+	tuning := Tuning{Requested: frequency, Achieved: next.Divider.DivideExact(next.Multiplier.MultiplyExact(s.exactReferenceFrequency(o.PLL)))}
+
+	if next.Reset {
+		if err := p.setupMultiplier(next.Multiplier); err != nil {
+			return Tuning{}, err
+		}
+		if err := o.setupDivider(next.Divider); err != nil {
+			return Tuning{}, err
+		}
+		if err := p.reset(); err != nil {
+			return Tuning{}, err
+		}
+		return tuning, s.awaitLock(o.PLL)
+	}
+
+	if p.IntegerMode && !next.Multiplier.IsInteger() {
+		if err := p.setIntegerMode(false); err != nil {
+			return Tuning{}, err
+		}
+	}
+	if o.IntegerMode && !next.Divider.IsInteger() {
+		if err := o.setIntegerMode(false); err != nil {
+			return Tuning{}, err
+		}
+	}
+	if err := p.tuneMultiplier(next.Multiplier); err != nil {
+		return Tuning{}, err
+	}
+	if err := o.tuneDivider(next.Divider); err != nil {
+		return Tuning{}, err
+	}
+	return tuning, nil
+}
+
+// tuningStrategy returns the TuningStrategy of the Si5351, FixedPLLStrategy is the default.
+func (s *Si5351) tuningStrategy() TuningStrategy {
+	if s.TuningStrategy == nil {
+		return FixedPLLStrategy{}
+	}
+	return s.TuningStrategy
+}
@@ -0,0 +1,107 @@
+package si5351
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedPLLStrategy(t *testing.T) {
+	ref := Crystal25MHz
+	current := TuningRatios{Multiplier: FractionalRatio{A: 36, B: 0, C: 1}}
+
+	ratios, err := FixedPLLStrategy{}.Ratios(ref, current, 7*MHz)
+	assert.NoError(t, err)
+	assert.Equal(t, current.Multiplier, ratios.Multiplier)
+	assert.Equal(t, uint32(TuningDenominator), ratios.Divider.C)
+	assert.False(t, ratios.Reset)
+	assert.InDelta(t, float64(7*MHz), float64(ratios.Divider.Divide(ratios.Multiplier.Multiply(ref))), 0.05)
+
+	ratios, err = FixedPLLStrategy{PLLFrequency: 800 * MHz}.Ratios(ref, current, 7*MHz)
+	assert.NoError(t, err)
+	assert.Equal(t, FractionalRatio{A: 32, B: 0, C: 1}, ratios.Multiplier)
+	assert.True(t, ratios.Reset)
+
+	ratios, err = FixedPLLStrategy{}.Ratios(ref, current, 180*MHz)
+	assert.NoError(t, err)
+	assert.True(t, ratios.Divider.By4)
+	assert.True(t, ratios.Reset)
+
+	_, err = FixedPLLStrategy{}.Ratios(ref, current, 201*MHz)
+	assert.Error(t, err)
+}
+
+func TestIntegerDividerStrategy(t *testing.T) {
+	ref := Crystal25MHz
+
+	ratios, err := IntegerDividerStrategy{}.Ratios(ref, TuningRatios{}, 7*MHz)
+	assert.NoError(t, err)
+	assert.True(t, ratios.Divider.IsInteger())
+	assert.True(t, ratios.Reset)
+
+	next, err := IntegerDividerStrategy{}.Ratios(ref, ratios, 7*MHz+100*Hz)
+	assert.NoError(t, err)
+	assert.Equal(t, ratios.Divider, next.Divider)
+	assert.False(t, next.Reset)
+}
+
+func TestHybridStrategy(t *testing.T) {
+	ref := Crystal25MHz
+
+	ratios, err := HybridStrategy{}.Ratios(ref, TuningRatios{}, 7*MHz)
+	assert.NoError(t, err)
+	assert.Equal(t, FractionalRatio{A: 108, B: 0, C: 1}, ratios.Divider)
+	assert.True(t, ratios.Reset)
+
+	changeovers := 0
+	current := ratios
+	for f := 7 * MHz; f <= 14*MHz; f += 10 * KHz {
+		next, err := HybridStrategy{}.Ratios(ref, current, f)
+		assert.NoError(t, err)
+		assert.True(t, next.Divider.IsInteger())
+		pllFrequency := next.Multiplier.Multiply(ref)
+		assert.True(t, withinPLLRange(pllFrequency), "%.0f", pllFrequency)
+		assert.True(t, math.Abs(float64(next.Divider.Divide(pllFrequency)-f)) < 0.5, "%.3f", f) // the resolution of the PLL is 25MHz / TuningDenominator
+		if next.Reset {
+			changeovers++
+			assert.NotEqual(t, current.Divider, next.Divider)
+		} else {
+			assert.Equal(t, current.Divider, next.Divider)
+		}
+		current = next
+	}
+	assert.Equal(t, 3, changeovers)
+
+	ratios, err = HybridStrategy{}.Ratios(ref, TuningRatios{}, 100*KHz)
+	assert.NoError(t, err)
+	assert.Equal(t, FractionalRatio{A: 938, B: 0, C: 1, ClockDivider: ClockBy8}, ratios.Divider)
+}
+
+func TestTuneWithStrategy(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.TuningStrategy = HybridStrategy{}
+	device.PrepareOutputs(PLLB, false, ClockInputMultisynth, OutputDrive2mA, OutputDisableLow, Clk2)
+
+	tuning, err := device.Tune(Clk2, 7*MHz)
+	assert.NoError(t, err)
+	assert.Equal(t, 7*MHz, tuning.Frequency())
+	assert.Equal(t, FractionalRatio{A: 108, B: 0, C: 1}, device.Clk2().FrequencyDivider)
+	assert.True(t, device.Clk2().IntegerMode)
+
+	writes := recordWrites(bus)
+	tuning, err = device.Tune(Clk2, 7*MHz+500*Hz)
+	assert.NoError(t, err)
+	deviation, _ := tuning.Deviation().Float64()
+	assert.True(t, math.Abs(deviation) < 0.5, "%v", tuning)
+	for _, w := range *writes {
+		assert.NotEqual(t, uint8(RegPLLReset), w.reg)
+		assert.False(t, w.reg >= RegMultisynth2Parameters && w.reg < RegMultisynth2Parameters+8, "write to %d", w.reg)
+	}
+	assert.False(t, device.PLLB().IntegerMode)
+	assert.True(t, device.Clk2().IntegerMode)
+
+	_, err = device.Tune(Clk6, 7*MHz)
+	assert.Error(t, err)
+}
@@ -0,0 +1,217 @@
+package si5351
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// TuningDenominator is the fixed denominator of all ratios that are written by TuneOutput and TunePLL.
+// With a fixed denominator, small frequency steps only change P1 and P2, the bytes of P3 are kept.
+const TuningDenominator = maxDenominator
+
+// TuneOutput retunes the given output to the closest possible value of the given frequency without resetting the PLL.
+// Only the bytes of the Multisynth parameters that actually change are written, the denominator and the R divider are kept.
+// Use TuneOutput for small steps, e.g. to use the output as VFO. The output must have been set up before with
+// SetOutputFrequency, SetOutputDivider, or Load, only CLK0-CLK5 with a fractional divider can be tuned.
+// The Multisynth runs in fractional mode while tuning.
+// The method returns the exact effective output frequency.
+func (s *Si5351) TuneOutput(output OutputIndex, frequency Frequency) (Tuning, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Variant.checkOutput(output); err != nil {
+		return Tuning{}, err
+	}
+	if output >= Clk6 {
+		return Tuning{}, fmt.Errorf("only CLK0-CLK5 can be tuned: %w", ErrUnsupportedOutput)
+	}
+	if err := checkOutputFrequency(frequency); err != nil {
+		return Tuning{}, err
+	}
+
+	o := s.fractionalOutput[output]
+	current := o.FrequencyDivider
+	if current.By4 {
+		return Tuning{}, fmt.Errorf("CLK%d uses the divide-by-4 mode and cannot be tuned, use SetOutputFrequency instead", output)
+	}
+
+	pllFrequency := s.exactPLLFrequency(o.PLL)
+	q := new(big.Rat).Quo(pllFrequency, frequency.Rat())
+	q.Quo(q, new(big.Rat).SetInt64(int64(current.ClockDivider.Factor())))
+	divider := ratioWithDenominator(q, TuningDenominator)
+	divider.ClockDivider = current.ClockDivider
+	if divider.A < MinMultisynthDivider || divider.A > MaxMultisynthDivider || (divider.A == MaxMultisynthDivider && divider.B > 0) {
+		base, _ := pllFrequency.Float64()
+		base /= float64(current.ClockDivider.Factor())
+		return Tuning{}, &RangeError{What: fmt.Sprintf("tuning range of CLK%d", output), Frequency: frequency, Min: Frequency(base / MaxMultisynthDivider), Max: Frequency(base / MinMultisynthDivider), Err: ErrOutputOutOfRange}
+	}
+
+	if o.IntegerMode {
+		if err := o.setIntegerMode(false); err != nil {
+			return Tuning{}, err
+		}
+	}
+	if err := o.tuneDivider(divider); err != nil {
+		return Tuning{}, err
+	}
+
+	return Tuning{Requested: frequency, Achieved: divider.DivideExact(pllFrequency)}, nil
+}
+
+// TunePLL retunes the given PLL to the closest possible value of the given frequency without resetting it.
+// Only the bytes of the feedback Multisynth parameters that actually change are written, the denominator is kept.
+// The frequency must be within 600MHz and 900MHz. All outputs that are driven by the PLL follow the frequency change.
+// The feedback Multisynth runs in fractional mode while tuning.
+// The method returns the exact effective PLL frequency.
+func (s *Si5351) TunePLL(pll PLLIndex, frequency Frequency) (Tuning, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := checkPLLFrequency(frequency); err != nil {
+		return Tuning{}, err
+	}
+
+	p := s.pll[pll]
+	refFrequency := s.exactReferenceFrequency(pll)
+	q := new(big.Rat).Quo(frequency.Rat(), refFrequency)
+	multiplier := ratioWithDenominator(q, TuningDenominator)
+	if multiplier.A < MinPLLMultiplier || multiplier.A > MaxPLLMultiplier || (multiplier.A == MaxPLLMultiplier && multiplier.B > 0) {
+		ref := s.referenceFrequency(pll)
+		return Tuning{}, &RangeError{What: "PLL multiplier", Frequency: frequency, Min: MinPLLMultiplier * ref, Max: MaxPLLMultiplier * ref, Err: ErrPLLOutOfRange}
+	}
+
+	if p.IntegerMode {
+		if err := p.setIntegerMode(false); err != nil {
+			return Tuning{}, err
+		}
+	}
+	if err := p.tuneMultiplier(multiplier); err != nil {
+		return Tuning{}, err
+	}
+
+	return Tuning{Requested: frequency, Achieved: multiplier.MultiplyExact(refFrequency)}, nil
+}
+
+// TuneDivider writes only the bytes of the given divider that differ from the current divider, see writeRatioUpdate.
+// The current divider must reflect the state of the device.
+func (o *FractionalOutput) TuneDivider(divider FractionalRatio) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.tuneDivider(divider)
+}
+
+func (o *FractionalOutput) tuneDivider(divider FractionalRatio) error {
+	if o.unsupported != nil {
+		return o.unsupported
+	}
+
+	if err := writeRatioUpdate(o.bus, o.Register.Divider, o.FrequencyDivider, divider); err != nil {
+		return err
+	}
+	o.FrequencyDivider = divider
+	return nil
+}
+
+// TuneMultiplier writes only the bytes of the given multiplier that differ from the current multiplier, see writeRatioUpdate.
+// The PLL is not reset. The current multiplier must reflect the state of the device.
+func (p *PLL) TuneMultiplier(multiplier FractionalRatio) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.tuneMultiplier(multiplier)
+}
+
+func (p *PLL) tuneMultiplier(multiplier FractionalRatio) error {
+	if err := writeRatioUpdate(p.bus, p.Register.Multiplier, p.Multiplier, multiplier); err != nil {
+		return err
+	}
+	p.Multiplier = multiplier
+	return nil
+}
+
+// ratioWithDenominator returns the ratio A + B/C with the given denominator that is closest to the given value.
+func ratioWithDenominator(q *big.Rat, c uint32) FractionalRatio {
+	a, remainder := new(big.Int).QuoRem(q.Num(), q.Denom(), new(big.Int))
+
+	// b = round(remainder * c / denom)
+	b := new(big.Int).Mul(remainder, big.NewInt(2*int64(c)))
+	b.Add(b, q.Denom())
+	b.Quo(b, new(big.Int).Mul(q.Denom(), big.NewInt(2)))
+	if b.Int64() == int64(c) {
+		a.Add(a, big.NewInt(1))
+		b.SetInt64(0)
+	}
+	return FractionalRatio{A: uint32(a.Uint64()), B: uint32(b.Int64()), C: c}
+}
+
+// The parts of the register representation of a ratio: bytes 0-4 contain P3[15:0], the R divider and P1,
+// bytes 5-7 contain P3[19:16] and P2.
+const p2Offset = 5
+
+// writeRatioUpdate writes the bytes of the register representation of the new ratio that differ from the old ratio.
+// If only P1 or only P2 change, the changed bytes are written in one burst. If both change, the part that is written
+// first is chosen so that the intermediate ratio lies as close as possible to the range between the old and the new ratio,
+// this avoids big frequency jumps while the bytes are written.
+func writeRatioUpdate(bus Bus, reg uint8, current, next FractionalRatio) error {
+	oldBytes, newBytes := current.Bytes(), next.Bytes()
+	first, last, changed := changedRange(oldBytes, newBytes)
+	if !changed {
+		return nil
+	}
+	if first >= p2Offset || last < p2Offset {
+		return writeRegisters(bus, reg+uint8(first), newBytes[first:last+1]...)
+	}
+
+	p1First := make([]byte, len(oldBytes))
+	copy(p1First, oldBytes)
+	copy(p1First[first:p2Offset], newBytes[first:p2Offset])
+	p2First := make([]byte, len(oldBytes))
+	copy(p2First, oldBytes)
+	copy(p2First[p2Offset:last+1], newBytes[p2Offset:last+1])
+
+	oldValue, newValue := registerValue(oldBytes), registerValue(newBytes)
+	if excursion(registerValue(p1First), oldValue, newValue) <= excursion(registerValue(p2First), oldValue, newValue) {
+		return writeRegisters(bus, reg+uint8(first), newBytes[first:last+1]...)
+	}
+	if err := writeRegisters(bus, reg+p2Offset, newBytes[p2Offset:last+1]...); err != nil {
+		return err
+	}
+	return writeRegisters(bus, reg+uint8(first), newBytes[first:p2Offset]...)
+}
+
+// changedRange returns the first and the last index at which the given byte slices differ.
+func changedRange(a, b []byte) (first, last int, changed bool) {
+	first, last = -1, -1
+	for i := range a {
+		if a[i] != b[i] {
+			if first == -1 {
+				first = i
+			}
+			last = i
+		}
+	}
+	return first, last, first != -1
+}
+
+// registerValue returns the value of the ratio that is represented by the given register bytes, (P1 + 512 + P2/P3) / 128.
+func registerValue(bytes []byte) float64 {
+	p1, p2, p3 := DecodeParameters(bytes)
+	if p3 == 0 {
+		return float64(p1+512) / 128
+	}
+	return (float64(p1+512) + float64(p2)/float64(p3)) / 128
+}
+
+// excursion returns the distance of the given value from the range between a and b.
+func excursion(value, a, b float64) float64 {
+	if a > b {
+		a, b = b, a
+	}
+	switch {
+	case value < a:
+		return a - value
+	case value > b:
+		return value - b
+	default:
+		return 0
+	}
+}
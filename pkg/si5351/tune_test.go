@@ -0,0 +1,118 @@
+package si5351
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordedWrite struct {
+	reg    uint8
+	values []byte
+}
+
+func recordWrites(bus *fakeBus) *[]recordedWrite {
+	result := new([]recordedWrite)
+	bus.onWrite = func(reg uint8, values []byte) {
+		*result = append(*result, recordedWrite{reg, append([]byte{}, values...)})
+	}
+	return result
+}
+
+func TestTuneOutput(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.SetupPLL(PLLA, 900*MHz)
+	device.PrepareOutputs(PLLA, false, ClockInputMultisynth, OutputDrive2mA, OutputDisableLow, Clk0)
+	device.SetOutputFrequency(Clk0, 7*MHz)
+	_, err := device.TuneOutput(Clk0, 7*MHz)
+	assert.NoError(t, err)
+
+	writes := recordWrites(bus)
+	for f := 7*MHz + 10*Hz; f < 7*MHz+3*KHz; f += 10 * Hz {
+		*writes = nil
+		tuning, err := device.TuneOutput(Clk0, f)
+
+		assert.NoError(t, err)
+		deviation, _ := tuning.Deviation().Float64()
+		assert.True(t, math.Abs(deviation) < 0.05, "%v", tuning)
+		written := 0
+		for _, w := range *writes {
+			assert.True(t, w.reg >= RegMultisynth0Parameters+2 && int(w.reg)+len(w.values) <= RegMultisynth0Parameters+8, "write to %d", w.reg)
+			written += len(w.values)
+		}
+		assert.True(t, written > 0 && written <= 6, "%d bytes written", written)
+	}
+
+	*writes = nil
+	_, err = device.TuneOutput(Clk0, 7*MHz+2990*Hz)
+	assert.NoError(t, err)
+	assert.Empty(t, *writes)
+
+	actual, err := ParseFractionalRatio(bus.registers[RegMultisynth0Parameters : RegMultisynth0Parameters+8])
+	assert.NoError(t, err)
+	assert.Equal(t, device.Clk0().FrequencyDivider, actual)
+	assert.False(t, device.Clk0().IntegerMode)
+}
+
+func TestTunePLL(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.SetupPLL(PLLB, 800*MHz)
+	assert.True(t, device.PLLB().IntegerMode)
+
+	writes := recordWrites(bus)
+	tuning, err := device.TunePLL(PLLB, 800*MHz+100*Hz)
+
+	assert.NoError(t, err)
+	assert.False(t, device.PLLB().IntegerMode)
+	deviation, _ := tuning.Deviation().Float64()
+	assert.True(t, math.Abs(deviation) < 12, "%v", tuning) // the resolution is 25MHz / TuningDenominator
+	for _, w := range *writes {
+		assert.NotEqual(t, uint8(RegPLLReset), w.reg)
+	}
+	actual, err := ParseFractionalRatio(bus.registers[RegPLLBMultisynthParameters : RegPLLBMultisynthParameters+8])
+	assert.NoError(t, err)
+	assert.Equal(t, device.PLLB().Multiplier, actual)
+
+	_, err = device.TunePLL(PLLB, 950*MHz)
+	assert.Error(t, err)
+}
+
+func TestTuneOutputInvalid(t *testing.T) {
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, new(fakeBus))
+	device.SetupPLL(PLLA, 900*MHz)
+	device.SetOutputFrequency(Clk0, 180*MHz)
+
+	_, err := device.TuneOutput(Clk0, 181*MHz)
+	assert.Error(t, err)
+	_, err = device.TuneOutput(Clk6, 10*MHz)
+	assert.Error(t, err)
+}
+
+func TestWriteRatioUpdateOrder(t *testing.T) {
+	bus := new(fakeBus)
+	writes := recordWrites(bus)
+	current := FractionalRatio{A: 36, B: 1, C: 3}
+	next := FractionalRatio{A: 36, B: 1, C: TuningDenominator}
+	current.WriteTo(bus.RegWriter(RegMultisynth0Parameters))
+	*writes = nil
+
+	err := writeRatioUpdate(bus, RegMultisynth0Parameters, current, next)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(*writes))
+	assert.Equal(t, uint8(RegMultisynth0Parameters+5), (*writes)[0].reg)
+	assert.Equal(t, uint8(RegMultisynth0Parameters), (*writes)[1].reg)
+	assert.Equal(t, next.Bytes(), bus.registers[RegMultisynth0Parameters:RegMultisynth0Parameters+8])
+
+	*writes = nil
+	current, next = next, FractionalRatio{A: 36, B: 2, C: TuningDenominator}
+	err = writeRatioUpdate(bus, RegMultisynth0Parameters, current, next)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(*writes))
+	assert.Equal(t, uint8(RegMultisynth0Parameters+6), (*writes)[0].reg)
+	assert.Equal(t, next.Bytes(), bus.registers[RegMultisynth0Parameters:RegMultisynth0Parameters+8])
+}
@@ -0,0 +1,117 @@
+package si5351
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Input describes the inputs of the Si5351.
+type Input uint8
+
+// All inputs of the Si5351.
+const (
+	InputCrystal Input = 1 << iota
+	InputClkin
+	InputVC
+)
+
+func (i Input) String() string {
+	switch i {
+	case InputCrystal:
+		return "XTAL"
+	case InputClkin:
+		return "CLKIN"
+	case InputVC:
+		return "VC"
+	default:
+		return fmt.Sprintf("input %d", uint8(i))
+	}
+}
+
+// Variant describes the capabilities of a specific variant of the Si5351 or of a compatible clone.
+type Variant struct {
+	Name string
+	// Outputs is the number of outputs, starting with CLK0.
+	Outputs int
+	// Inputs contains all inputs of the variant.
+	Inputs Input
+}
+
+// The known variants.
+var (
+	Si5351A10MSOP = Variant{Name: "Si5351A-10MSOP", Outputs: 3, Inputs: InputCrystal}
+	Si5351A20QFN  = Variant{Name: "Si5351A-20QFN", Outputs: 8, Inputs: InputCrystal}
+	Si5351B       = Variant{Name: "Si5351B", Outputs: 8, Inputs: InputCrystal | InputVC}
+	Si5351C       = Variant{Name: "Si5351C", Outputs: 8, Inputs: InputCrystal | InputClkin}
+	MS5351M       = Variant{Name: "MS5351M", Outputs: 3, Inputs: InputCrystal}
+)
+
+// Variants contains all known variants.
+var Variants = []Variant{
+	Si5351A10MSOP,
+	Si5351A20QFN,
+	Si5351B,
+	Si5351C,
+	MS5351M,
+}
+
+// FindVariant returns the known variant with the given name. The name is not case sensitive.
+func FindVariant(name string) (Variant, bool) {
+	for _, variant := range Variants {
+		if strings.EqualFold(variant.Name, name) {
+			return variant, true
+		}
+	}
+	return Variant{}, false
+}
+
+func (v Variant) String() string {
+	return v.Name
+}
+
+// HasOutput indicates if the variant has the given output.
+func (v Variant) HasOutput(output OutputIndex) bool {
+	return output >= Clk0 && int(output) < v.Outputs
+}
+
+// HasInput indicates if the variant has the given input.
+func (v Variant) HasInput(input Input) bool {
+	return v.Inputs&input != 0
+}
+
+// The errors that indicate that a variant does not support something.
+var (
+	ErrUnsupportedOutput = errors.New("unsupported output")
+	ErrUnsupportedInput  = errors.New("unsupported input")
+)
+
+// UnsupportedError indicates that the variant of the Si5351 does not have an output or input that was used.
+type UnsupportedError struct {
+	Variant Variant
+	What    string
+	Err     error
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("%v does not have %s", e.Variant, e.What)
+}
+
+// Unwrap returns ErrUnsupportedOutput or ErrUnsupportedInput.
+func (e *UnsupportedError) Unwrap() error {
+	return e.Err
+}
+
+func (v Variant) checkOutput(output OutputIndex) error {
+	if v.HasOutput(output) {
+		return nil
+	}
+	return &UnsupportedError{Variant: v, What: fmt.Sprintf("output CLK%d", output), Err: ErrUnsupportedOutput}
+}
+
+func (v Variant) checkInput(input Input) error {
+	if v.HasInput(input) {
+		return nil
+	}
+	return &UnsupportedError{Variant: v, What: fmt.Sprintf("input %v", input), Err: ErrUnsupportedInput}
+}
@@ -0,0 +1,55 @@
+package si5351
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindVariant(t *testing.T) {
+	variant, ok := FindVariant("si5351a-10msop")
+	assert.True(t, ok)
+	assert.Equal(t, Si5351A10MSOP, variant)
+
+	_, ok = FindVariant("Si5351D")
+	assert.False(t, ok)
+}
+
+func TestUnsupportedOutputs(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351A10MSOP, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.SetupPLL(PLLA, 900*MHz)
+
+	assert.NoError(t, device.PrepareOutputs(PLLA, false, ClockInputMultisynth, OutputDrive2mA, OutputDisableLow, Clk0, Clk2))
+	_, err := device.SetOutputFrequency(Clk2, 10*MHz)
+	assert.NoError(t, err)
+
+	var unsupportedErr *UnsupportedError
+	err = device.PrepareOutputs(PLLA, false, ClockInputMultisynth, OutputDrive2mA, OutputDisableLow, Clk1, Clk3)
+	assert.True(t, errors.As(err, &unsupportedErr))
+	assert.True(t, errors.Is(err, ErrUnsupportedOutput))
+	assert.Equal(t, byte(0), bus.registers[RegClk1Control], "nothing written")
+
+	_, err = device.SetOutputFrequency(Clk3, 10*MHz)
+	assert.True(t, errors.Is(err, ErrUnsupportedOutput))
+	_, err = device.SetOutputDivider(Clk7, 10, 0, 1)
+	assert.True(t, errors.Is(err, ErrUnsupportedOutput))
+	_, _, err = device.SetupQuadratureOutput(PLLA, Clk0, Clk4, 10*MHz)
+	assert.True(t, errors.Is(err, ErrUnsupportedOutput))
+	assert.True(t, errors.Is(device.EnableOutputs(true, Clk5), ErrUnsupportedOutput))
+	assert.True(t, errors.Is(device.Clk6().SetDrive(OutputDrive8mA), ErrUnsupportedOutput))
+	assert.True(t, errors.Is(device.Output(Clk3).Enable(true), ErrUnsupportedOutput))
+	assert.True(t, errors.Is(device.SetupMultisynthRaw(Clk7, 10, 0, 1, ClockBy1), ErrUnsupportedOutput))
+	assert.True(t, errors.Is(device.SetupMultisynthRaw(OutputIndex(8), 10, 0, 1, ClockBy1), ErrUnsupportedOutput))
+}
+
+func TestUnsupportedInputs(t *testing.T) {
+	device := New(Si5351A20QFN, Crystal{BaseFrequency: Crystal25MHz}, new(fakeBus))
+
+	assert.True(t, errors.Is(device.SetupClkin(Clkin{BaseFrequency: 10 * MHz}, PLLA), ErrUnsupportedInput))
+	assert.True(t, errors.Is(device.SetupPLLInputSource(ClockBy1, PLLInputCrystal, PLLInputClkin), ErrUnsupportedInput))
+	assert.NoError(t, device.SetupPLLInputSource(ClockBy1, PLLInputCrystal, PLLInputCrystal))
+	device.SetupPLLRaw(PLLB, 36, 0, 1)
+	assert.True(t, errors.Is(device.SetupVCXO(100), ErrUnsupportedInput))
+}
@@ -0,0 +1,61 @@
+package si5351
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The limits of the VCXO pull range in ppm.
+const (
+	MinVCXOPullRange = 30
+	MaxVCXOPullRange = 240
+)
+
+// FindVCXOParameter calculates the VCXO parameter for the given PLL B multiplier and the given absolute pull range in ppm.
+func FindVCXOParameter(multiplier FractionalRatio, pullRangePPM float64) (uint32, error) {
+	if pullRangePPM < MinVCXOPullRange || pullRangePPM > MaxVCXOPullRange {
+		return 0, fmt.Errorf("invalid VCXO pull range %.0fppm, must be within %d-%dppm", pullRangePPM, MinVCXOPullRange, MaxVCXOPullRange)
+	}
+	if multiplier.A == 0 {
+		return 0, errors.New("PLL B must be set up before the VCXO")
+	}
+	if !multiplier.IsInteger() {
+		return 0, fmt.Errorf("the VCXO requires PLL B in integer mode with an even integer multiplier, but its multiplier is %d %d/%d", multiplier.A, multiplier.B, multiplier.C)
+	}
+
+	// VCXO_Param = 1.03 * (128a + b/10^6) * APR, b = 0 in integer mode
+	parameter := uint32(1.03*float64(128*multiplier.A)*pullRangePPM + 0.5)
+	if parameter > 0x3FFFFF {
+		return 0, fmt.Errorf("VCXO parameter %d out of range", parameter)
+	}
+	return parameter, nil
+}
+
+// SetupVCXO sets up the VC input of the Si5351B to pull PLL B by the given absolute pull range in ppm.
+// The VCXO requires PLL B to run in integer mode, therefore set up PLL B with an even integer multiplier first.
+func (s *Si5351) SetupVCXO(pullRangePPM float64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Variant.checkInput(InputVC); err != nil {
+		return err
+	}
+	parameter, err := FindVCXOParameter(s.PLLB().Multiplier, pullRangePPM)
+	if err != nil {
+		return err
+	}
+	if !s.PLLB().IntegerMode {
+		return errors.New("the VCXO requires PLL B in integer mode")
+	}
+
+	err = writeRegisters(s.bus, RegVCXOParameters,
+		byte(parameter&0x0000FF),
+		byte((parameter&0x00FF00)>>8),
+		byte((parameter&0x3F0000)>>16),
+	)
+	if err != nil {
+		return err
+	}
+	s.VCXOPullRange = pullRangePPM
+	return nil
+}
@@ -0,0 +1,37 @@
+package si5351
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetupVCXO(t *testing.T) {
+	bus := new(fakeBus)
+	device := New(Si5351B, Crystal{BaseFrequency: Crystal25MHz}, bus)
+	device.SetupPLLRaw(PLLB, 36, 0, 1)
+
+	err := device.SetupVCXO(100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, float64(100), device.VCXOPullRange)
+	assert.Equal(t, []byte{0x00, 0x3E, 0x07}, bus.registers[RegVCXOParameters:RegVCXOParameters+3])
+}
+
+func TestSetupVCXOInvalid(t *testing.T) {
+	device := New(Si5351B, Crystal{BaseFrequency: Crystal25MHz}, new(fakeBus))
+
+	assert.Error(t, device.SetupVCXO(100), "PLL B not set up")
+
+	device.SetupPLLRaw(PLLB, 36, 1, 3)
+	assert.Error(t, device.SetupVCXO(100), "PLL B not in integer mode")
+
+	device.SetupPLLRaw(PLLB, 35, 0, 1)
+	assert.Error(t, device.SetupVCXO(100), "odd integer multiplier")
+	_, err := FindVCXOParameter(FractionalRatio{A: 35, B: 0, C: 1}, 100)
+	assert.Error(t, err)
+
+	device.SetupPLLRaw(PLLB, 36, 0, 1)
+	assert.Error(t, device.SetupVCXO(20))
+	assert.Error(t, device.SetupVCXO(250))
+}